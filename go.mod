@@ -6,15 +6,24 @@ require (
 	github.com/armosec/armoapi-go v0.0.211
 	github.com/armosec/utils-go v0.0.20
 	github.com/francoispqt/gojay v1.2.13
+	github.com/go-logr/logr v1.4.1
 	github.com/golang/glog v1.0.0
+	github.com/prometheus/client_golang v1.19.1
 	github.com/stretchr/testify v1.8.4
 )
 
 require (
 	github.com/armosec/gojay v1.2.15 // indirect
 	github.com/armosec/utils-k8s-go v0.0.16 // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
 	github.com/stripe/stripe-go/v74 v74.28.0 // indirect
+	golang.org/x/sys v0.17.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,116 @@
+package datastructures
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+ActionIDs of parallel steps
+
+An ActionID is a dotted path. "3" is the 3rd step of a job, Fork gives its parallel branches "3.1", "3.2"... and a branch
+may fork again ("3.1.1"). A branch keeps its ActionID until it is forked, and Join moves the forked report to "4".
+ActionIDN holds the first number of the path, so it still orders the steps, branches of the same step are ordered by
+CompareActionIDs.
+*/
+
+// ParseActionID parses a dotted ActionID, eg. "3.1" -> [3 1]
+func ParseActionID(actionID string) ([]int, error) {
+	if actionID == "" {
+		return nil, fmt.Errorf("empty actionID")
+	}
+	parts := strings.Split(actionID, ".")
+	path := make([]int, len(parts))
+	for i := range parts {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid actionID '%s'", actionID)
+		}
+		path[i] = n
+	}
+	return path, nil
+}
+
+// CompareActionIDs orders two ActionIDs: -1 if a comes before b, 1 if after and 0 if they are equal.
+// "3" < "3.1" < "3.2" < "3.10" < "4". Invalid ActionIDs are compared as strings, after the valid ones
+func CompareActionIDs(a, b string) int {
+	pathA, errA := ParseActionID(a)
+	pathB, errB := ParseActionID(b)
+	switch {
+	case errA != nil && errB != nil:
+		return strings.Compare(a, b)
+	case errA != nil:
+		return 1
+	case errB != nil:
+		return -1
+	}
+	for i := 0; i < len(pathA) && i < len(pathB); i++ {
+		if pathA[i] != pathB[i] {
+			if pathA[i] < pathB[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(pathA) < len(pathB):
+		return -1
+	case len(pathA) > len(pathB):
+		return 1
+	}
+	return 0
+}
+
+// isBranchActionID returns true for the ActionID of a forked branch, eg. "3.1"
+func isBranchActionID(actionID string) bool {
+	return strings.Contains(actionID, ".")
+}
+
+/*
+Fork returns n reports for parallel branches of the current action, numbered <ActionID>.1 to <ActionID>.n.
+
+The branches share the jobID of the report, so fork after the jobID was assigned. Call Join once the branches are done.
+Fork returns nil for n <= 0
+*/
+func (report *BaseReport) Fork(n int) []*BaseReport {
+	if n <= 0 {
+		return nil
+	}
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	base := report.ActionID
+	if base == "" {
+		base = strconv.Itoa(report.ActionIDN)
+	}
+	branches := make([]*BaseReport, n)
+	for i := range branches {
+		branch := report.unprotectedNewChildReport()
+		branch.Target = report.Target
+		branch.Status = report.Status
+		branch.ActionName = report.ActionName
+		branch.JobID = report.JobID
+		branch.ParentAction = report.ParentAction
+		branch.ActionIDN = report.ActionIDN
+		branch.ActionID = fmt.Sprintf("%s.%d", base, i+1)
+		branches[i] = branch
+	}
+	return branches
+}
+
+/*
+Join waits for the reports the branches are sending and moves the report to the action after the fork, eg. "3" to "4".
+
+Joining the nested branches of a branch leaves the branch ActionID unchanged - "3.1" joined from "3.1.1" and "3.1.2"
+stays "3.1", since "3.2" is the ActionID of its sibling branch. The reports the branch sends after the join are ordered
+after the nested ones by their Timestamp (see SortTimeline)
+*/
+func (report *BaseReport) Join(branches ...*BaseReport) {
+	for _, branch := range branches {
+		branch.mutex.Lock()
+		branch.mutex.Unlock()
+	}
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	report.NextActionID()
+}
@@ -0,0 +1,208 @@
+package datastructures
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// JobsAnnotationKey the object metadata annotation holding the JobsAnnotations
+	JobsAnnotationKey = "armo.jobs"
+	// JobsAnnotationsVersion the current JobsAnnotations schema version. Annotations without a version are version 1
+	JobsAnnotationsVersion = 1
+
+	maxJobIDLength = 253
+)
+
+// ErrUnsupportedJobsAnnotationsVersion is returned when reading annotations written with a newer schema version
+var ErrUnsupportedJobsAnnotationsVersion = errors.New("unsupported jobs annotations version")
+
+// NewJobsAnnotations returns the annotations to pass the job on to another component, see SimpleReportAnnotations
+func (report *BaseReport) NewJobsAnnotations(setParent bool, setCurrent bool) JobsAnnotations {
+	jobs := JobsAnnotations{Version: JobsAnnotationsVersion, LastActionID: report.GetNextActionId()}
+	InjectTraceAnnotations(&jobs, report.traceContext)
+	if setParent {
+		jobs.ParentJobID = report.JobID
+	}
+	if setCurrent {
+		jobs.CurrJobID = report.JobID
+	}
+	return jobs
+}
+
+// NewJobContext returns the job context to pass the job on to another component, see NewJobsAnnotations
+func (report *BaseReport) NewJobContext(setParent bool, setCurrent bool) JobContext {
+	jobs := report.NewJobsAnnotations(setParent, setCurrent)
+	return JobContext{JobID: jobs.CurrJobID, ParentJobID: jobs.ParentJobID, ActionID: jobs.LastActionID}
+}
+
+// SetContext sets the job of the context, eg. "attach" or "scan/namespace"
+func (jobs *JobsAnnotations) SetContext(context string, jobContext JobContext) {
+	if jobs.Contexts == nil {
+		jobs.Contexts = map[string]JobContext{}
+	}
+	jobs.Contexts[context] = jobContext
+}
+
+/*
+ForContext returns the annotations of the job of the context.
+
+A nested context falls back to its parent context ("scan/namespace" -> "scan") and any context falls back to the top
+level job, so annotations written without contexts (eg. by SimpleReportAnnotations) serve every context.
+found is false if the top level job was returned
+*/
+func (jobs *JobsAnnotations) ForContext(context string) (JobsAnnotations, bool) {
+	for name := context; name != ""; name = parentContext(name) {
+		if jobContext, ok := jobs.Contexts[name]; ok {
+			return JobsAnnotations{
+				CurrJobID:    jobContext.JobID,
+				ParentJobID:  jobContext.ParentJobID,
+				LastActionID: jobContext.ActionID,
+				Contexts:     jobs.Contexts,
+				TraceParent:  jobs.TraceParent,
+				TraceState:   jobs.TraceState,
+				Version:      jobs.Version,
+			}, true
+		}
+	}
+	return *jobs, false
+}
+
+func parentContext(context string) string {
+	if i := strings.LastIndex(context, "/"); i >= 0 {
+		return context[:i]
+	}
+	return ""
+}
+
+// Validate checks the jobIDs and the actionIDs of the annotations and their contexts
+func (jobs *JobsAnnotations) Validate() error {
+	if jobs.Version < 0 {
+		return fmt.Errorf("invalid jobs annotations version %d", jobs.Version)
+	}
+	if jobs.Version > JobsAnnotationsVersion {
+		return fmt.Errorf("%w: %d", ErrUnsupportedJobsAnnotationsVersion, jobs.Version)
+	}
+	if err := validateJobID(jobs.CurrJobID); err != nil {
+		return fmt.Errorf("invalid jobID: %w", err)
+	}
+	if err := validateJobID(jobs.ParentJobID); err != nil {
+		return fmt.Errorf("invalid parentJobID: %w", err)
+	}
+	if jobs.LastActionID != "" {
+		if _, err := ParseActionID(jobs.LastActionID); err != nil {
+			return err
+		}
+	}
+	if jobs.TraceParent != "" {
+		if _, err := ParseTraceParent(jobs.TraceParent); err != nil {
+			return err
+		}
+	}
+	for context, jobContext := range jobs.Contexts {
+		if err := jobContext.validate(context); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (jobContext *JobContext) validate(context string) error {
+	if context == "" || strings.HasPrefix(context, "/") || strings.HasSuffix(context, "/") {
+		return fmt.Errorf("invalid context name '%s'", context)
+	}
+	if err := validateJobID(jobContext.JobID); err != nil {
+		return fmt.Errorf("context '%s': invalid jobID: %w", context, err)
+	}
+	if err := validateJobID(jobContext.ParentJobID); err != nil {
+		return fmt.Errorf("context '%s': invalid parentJobID: %w", context, err)
+	}
+	if jobContext.ActionID != "" {
+		if _, err := ParseActionID(jobContext.ActionID); err != nil {
+			return fmt.Errorf("context '%s': %w", context, err)
+		}
+	}
+	return nil
+}
+
+func validateJobID(jobID string) error {
+	if len(jobID) > maxJobIDLength {
+		return fmt.Errorf("longer than %d characters", maxJobIDLength)
+	}
+	for _, r := range jobID {
+		if unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return fmt.Errorf("'%s' contains whitespace or non printable characters", jobID)
+		}
+	}
+	return nil
+}
+
+// SetJobsAnnotations validates the jobs annotations and writes them to the object annotations under JobsAnnotationKey.
+// A nil annotations map is allocated, the written map is returned
+func SetJobsAnnotations(annotations map[string]string, jobs JobsAnnotations) (map[string]string, error) {
+	if jobs.Version == 0 {
+		jobs.Version = JobsAnnotationsVersion
+	}
+	if err := jobs.Validate(); err != nil {
+		return annotations, err
+	}
+	value, err := json.Marshal(jobs)
+	if err != nil {
+		return annotations, fmt.Errorf("failed to marshal jobs annotations: %w", err)
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[JobsAnnotationKey] = string(value)
+	return annotations, nil
+}
+
+/*
+GetJobsAnnotations reads the jobs annotations from the object annotations
+@returns:
+
+	the jobs annotations,
+	found - false if there is no JobsAnnotationKey annotation,
+	error - if the value is malformed or invalid. The fields that could be read are still returned, so a malformed
+	annotation does not break the caller
+*/
+func GetJobsAnnotations(annotations map[string]string) (JobsAnnotations, bool, error) {
+	value, ok := annotations[JobsAnnotationKey]
+	if !ok {
+		return JobsAnnotations{}, false, nil
+	}
+	jobs, err := ParseJobsAnnotations(value)
+	return jobs, true, err
+}
+
+// ParseJobsAnnotations parses and validates a jobs annotations value. A JSON string holding the object (double encoded) is
+// accepted as well
+func ParseJobsAnnotations(value string) (JobsAnnotations, error) {
+	jobs := JobsAnnotations{}
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, `"`) {
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return jobs, fmt.Errorf("malformed jobs annotations: %w", err)
+		}
+		value = unquoted
+	}
+	if err := json.Unmarshal([]byte(value), &jobs); err != nil {
+		// keep whatever fields can be read
+		fields := map[string]interface{}{}
+		if json.Unmarshal([]byte(value), &fields) == nil {
+			jobs.CurrJobID, _ = fields["jobID"].(string)
+			jobs.ParentJobID, _ = fields["parentJobID"].(string)
+			jobs.LastActionID, _ = fields["actionID"].(string)
+		}
+		return jobs, fmt.Errorf("malformed jobs annotations: %w", err)
+	}
+	if jobs.Version == 0 {
+		jobs.Version = 1
+	}
+	return jobs, jobs.Validate()
+}
@@ -0,0 +1,217 @@
+package datastructures
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultAPIKeyHeader = "X-API-KEY"
+
+	// HMAC request signing headers, see HMACAuthenticator
+	SignatureHeader          = "X-Signature"
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+	SignatureKeyIDHeader     = "X-Signature-Key-Id"
+)
+
+// Authenticator authenticates the requests to the event receiver
+type Authenticator interface {
+	// Authenticate returns the headers authenticating a request with the body
+	Authenticate(ctx context.Context, method, requestUrl string, body []byte) (map[string]string, error)
+}
+
+// APIKeyAuthenticator sends a static API key header
+type APIKeyAuthenticator struct {
+	Header string // default X-API-KEY
+	Key    string
+}
+
+func (a *APIKeyAuthenticator) Authenticate(ctx context.Context, method, requestUrl string, body []byte) (map[string]string, error) {
+	header := a.Header
+	if header == "" {
+		header = DefaultAPIKeyHeader
+	}
+	return map[string]string{header: a.Key}, nil
+}
+
+/*
+BearerTokenFileAuthenticator sends an "Authorization: Bearer <token>" header with the token read from a file, eg. a
+projected service account token. The file is read again once it changed, so rotated tokens are picked up
+*/
+type BearerTokenFileAuthenticator struct {
+	path string
+
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+	size    int64
+}
+
+// NewBearerTokenFileAuthenticator returns an authenticator reading the token from the file, on the first request
+func NewBearerTokenFileAuthenticator(path string) *BearerTokenFileAuthenticator {
+	return &BearerTokenFileAuthenticator{path: path}
+}
+
+func (a *BearerTokenFileAuthenticator) Authenticate(ctx context.Context, method, requestUrl string, body []byte) (map[string]string, error) {
+	token, err := a.Token()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"Authorization": "Bearer " + token}, nil
+}
+
+// Token returns the token, reading the file again if it changed. If the file cannot be read, the last token is kept
+func (a *BearerTokenFileAuthenticator) Token() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	info, err := os.Stat(a.path)
+	if err != nil {
+		if a.token != "" {
+			return a.token, nil
+		}
+		return "", fmt.Errorf("failed to read bearer token: %w", err)
+	}
+	if a.token != "" && info.ModTime().Equal(a.modTime) && info.Size() == a.size {
+		return a.token, nil
+	}
+	content, err := os.ReadFile(a.path)
+	if err != nil {
+		if a.token != "" {
+			return a.token, nil
+		}
+		return "", fmt.Errorf("failed to read bearer token: %w", err)
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		if a.token != "" {
+			return a.token, nil
+		}
+		return "", fmt.Errorf("bearer token file '%s' is empty", a.path)
+	}
+	a.token, a.modTime, a.size = token, info.ModTime(), info.Size()
+	return a.token, nil
+}
+
+/*
+HMACAuthenticator signs the requests with HMAC-SHA256. The signature is the hex encoded HMAC of
+
+	<unix timestamp>\n<method>\n<url path>\n<hex sha256 of the body>
+
+sent in the X-Signature header, with the timestamp in X-Signature-Timestamp and the key ID (if set) in X-Signature-Key-Id.
+The receiver verifies it with VerifyHMACSignature
+*/
+type HMACAuthenticator struct {
+	KeyID  string
+	Secret []byte
+	Now    func() time.Time // default time.Now
+}
+
+func (a *HMACAuthenticator) Authenticate(ctx context.Context, method, requestUrl string, body []byte) (map[string]string, error) {
+	if len(a.Secret) == 0 {
+		return nil, fmt.Errorf("hmac secret is not set")
+	}
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+	timestamp := strconv.FormatInt(now().Unix(), 10)
+	signature, err := hmacSignature(a.Secret, timestamp, method, requestUrl, body)
+	if err != nil {
+		return nil, err
+	}
+	headers := map[string]string{SignatureHeader: signature, SignatureTimestampHeader: timestamp}
+	if a.KeyID != "" {
+		headers[SignatureKeyIDHeader] = a.KeyID
+	}
+	return headers, nil
+}
+
+/*
+VerifyHMACSignature verifies the signature of a request signed by HMACAuthenticator
+@Input:
+secret - the shared secret
+header - returns the header of the request, eg. http.Header.Get
+maxSkew - how old (or in the future) the timestamp may be, 0 for no limit
+*/
+func VerifyHMACSignature(secret []byte, method, requestUrl string, body []byte, header func(string) string, maxSkew time.Duration) error {
+	timestamp := header(SignatureTimestampHeader)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid signature timestamp '%s'", timestamp)
+	}
+	if skew := time.Since(time.Unix(seconds, 0)); maxSkew > 0 && (skew > maxSkew || skew < -maxSkew) {
+		return fmt.Errorf("signature timestamp is off by %v", skew.Round(time.Second))
+	}
+	expected, err := hmacSignature(secret, timestamp, method, requestUrl, body)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(expected), []byte(header(SignatureHeader))) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+func hmacSignature(secret []byte, timestamp, method, requestUrl string, body []byte) (string, error) {
+	u, err := url.Parse(requestUrl)
+	if err != nil {
+		return "", fmt.Errorf("invalid url '%s': %w", requestUrl, err)
+	}
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", timestamp, strings.ToUpper(method), u.EscapedPath(), hex.EncodeToString(bodyHash[:]))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// ChainAuthenticators returns an authenticator sending the headers of all the authenticators, eg. an API key and a signature
+func ChainAuthenticators(authenticators ...Authenticator) Authenticator {
+	return authenticatorChain(authenticators)
+}
+
+type authenticatorChain []Authenticator
+
+func (chain authenticatorChain) Authenticate(ctx context.Context, method, requestUrl string, body []byte) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, authenticator := range chain {
+		h, err := authenticator.Authenticate(ctx, method, requestUrl, body)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range h {
+			headers[k] = v
+		}
+	}
+	return headers, nil
+}
+
+// authenticatedHeaders returns the headers of a request, with the headers of the authenticator (if set)
+func authenticatedHeaders(ctx context.Context, authenticator Authenticator, method, requestUrl string, body []byte) (map[string]string, error) {
+	headers := map[string]string{"Content-Type": "application/json"}
+	if authenticator == nil {
+		return headers, nil
+	}
+	authHeaders, err := authenticator.Authenticate(ctx, method, requestUrl, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to authenticate the request: %w", err)
+	}
+	for k, v := range authHeaders {
+		headers[k] = v
+	}
+	return headers, nil
+}
+
+// SetAuthenticator sets the authenticator of the requests of the report to the event receiver
+func (report *BaseReport) SetAuthenticator(authenticator Authenticator) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	report.authenticator = authenticator
+}
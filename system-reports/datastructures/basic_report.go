@@ -2,6 +2,7 @@
 package datastructures
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"strings"
@@ -62,22 +63,60 @@ func (e *sysEndpoint) GetOrDefault() string {
 	return e.Get()
 }
 
+// SetDefaultEndpoint sets the path reports are posted to when neither the report nor its factory set one
+// (see BaseReport.SetEndpoint and WithEndpoint), an empty value restores the default "/k8s/sysreport".
+// A path not starting with "/" is logged and leaves the default endpoint unchanged
+func SetDefaultEndpoint(endpoint string) {
+	if err := validateEndpoint(endpoint); err != nil {
+		NewRedactingLogger(GetLogger(), GetRedactor()).Error("default endpoint was not set", err)
+		return
+	}
+	systemReportEndpoint.SetOrDefault(endpoint)
+}
+
+// validateEndpoint returns an error if the endpoint is set but is not a path starting with "/"
+func validateEndpoint(endpoint string) error {
+	if endpoint != "" && !strings.HasPrefix(endpoint, "/") {
+		return fmt.Errorf("invalid endpoint '%s', expected a path starting with '/'", endpoint)
+	}
+	return nil
+}
+
+// GetDefaultEndpoint returns the path reports are posted to when neither the report nor its factory set one
+func GetDefaultEndpoint() string {
+	return systemReportEndpoint.GetOrDefault()
+}
+
 // JobsAnnotations job annotation
 type JobsAnnotations struct {
-	/* jobID: context   eg. if a certain job has multiple stages
-	  eg. attach namespace>attach wlid in ns
-	  so obj when pod is cached should look like:
-	  {
-		  jobID#1: {
-			"attach namespace"
-		  }
-	  }
-	  - SHOULD BE RETHINK
-	*/
-	// JobIDsContex map[string]string `json:"jobIDsContex,omitempty"`
 	CurrJobID    string `json:"jobID"`       //simplest case (for now till we have a better idea)
 	ParentJobID  string `json:"parentJobID"` //simplest case (for now till we have a better idea)
 	LastActionID string `json:"actionID"`    //simplest case (for now till we have a better idea) used to pass as defining ordering between multiple components
+
+	/* Contexts the jobs of an object by context, when a certain job has multiple stages, eg.
+	  {
+		"jobID": "<autoattach>", ...
+		"contexts": {
+		  "attach": {"jobID": "<attach>", "actionID": "2"},
+		  "scan/namespace": {"jobID": "<scan namespace>", "parentJobID": "<scan>", "actionID": "1"}
+		}
+	  }
+	  see JobsAnnotations.ForContext
+	*/
+	Contexts map[string]JobContext `json:"contexts,omitempty"`
+
+	// W3C trace context of the job, see InjectTraceAnnotations
+	TraceParent string `json:"traceparent,omitempty"`
+	TraceState  string `json:"tracestate,omitempty"`
+
+	Version int `json:"version,omitempty"` // schema version, see JobsAnnotationsVersion
+}
+
+// JobContext the job of a single context in JobsAnnotations
+type JobContext struct {
+	JobID       string `json:"jobID"`
+	ParentJobID string `json:"parentJobID,omitempty"`
+	ActionID    string `json:"actionID"`
 }
 
 //BaseReport : represents the basic reports from various actions eg. attach and so on
@@ -98,29 +137,41 @@ type JobsAnnotations struct {
 type StatusType string
 
 const (
-	JobSuccess string = "success"
-	JobFailed  string = "failure"
-	JobWarning string = "warning"
-	JobStarted string = "started"
-	JobDone    string = "done"
+	JobSuccess StatusType = "success"
+	JobFailed  StatusType = "failure"
+	JobWarning StatusType = "warning"
+	JobStarted StatusType = "started"
+	JobDone    StatusType = "done"
 )
 
 type BaseReport struct {
 	CustomerGUID     string                `json:"customerGUID"` // customerGUID as declared in environment
 	Reporter         string                `json:"reporter"`     // component reporting the event
 	Target           string                `json:"target"`       // wlid, cluster,etc. - which component this event is applicable on
-	Status           string                `json:"status"`       // Action scope: Before action use "started", after action use "failure/success". Reporter scope: Before action use "started", after action use "done".
+	Status           StatusType            `json:"status"`       // Action scope: Before action use "started", after action use "failure/success". Reporter scope: Before action use "started", after action use "done".
 	ActionName       string                `json:"action"`       // Stage action. short description of the action to-be-done. When defining an action
 	Errors           []string              `json:"errors,omitempty"`
+	ErrorDetails     []ErrorDetail         `json:"errorDetails,omitempty"` // structured version of Errors
 	ActionID         string                `json:"actionID"`               // Stage counter of the E2E process. initialize at 1. The number is increased when sending job report
 	ActionIDN        int                   `json:"numSeq"`                 // The ActionID in number presentation
 	JobID            string                `json:"jobID"`                  // UID received from the eventReceiver after first report (the initializing is part of the first report)
 	ParentAction     string                `json:"parentAction,omitempty"` // Parent JobID
 	Details          string                `json:"details,omitempty"`      // Details of the action
 	Timestamp        time.Time             `json:"timestamp"`              //
+	Labels           map[string]string     `json:"labels,omitempty"`       // free form labels, eg. cluster name
 	mutex            sync.Mutex            `json:"-"`                      // ignore
 	eventReceiverUrl string                `json:"-"`                      // event receiver url
+	endpoint         string                `json:"-"`                      // path the report is posted to, default the global endpoint (see SetDefaultEndpoint)
 	httpClient       httputils.IHttpClient `json:"-"`                      // http client
+	transport        Transport             `json:"-"`                      // delivers the report, default posts it to the event receiver
+	retryPolicy      RetryPolicy           `json:"-"`                      // retry policy of Send, default follows MAX_RETRIES and RETRY_DELAY
+	outbox           Outbox                `json:"-"`                      // keeps reports that could not be delivered, optional
+	batchingSender   *BatchingSender       `json:"-"`                      // sends the report in bulk with other reports, optional
+	strictStatus     bool                  `json:"-"`                      // reject invalid status transitions instead of only logging them
+	traceContext     TraceContext          `json:"-"`                      // W3C trace context, sent as the traceparent header
+	logger           Logger                `json:"-"`                      // logger of the report, default the global logger
+	redactor         *Redactor             `json:"-"`                      // redacts the report, errors and logs. Default the global redactor, for the errors and logs only
+	authenticator    Authenticator         `json:"-"`                      // authenticates the requests to the event receiver, optional
 }
 
 //
@@ -137,8 +188,11 @@ type BaseReport struct {
 // }
 
 // NewBaseReport return pointer to new BaseReport obj
+//
+// a "file://" eventReceiverUrl writes the reports to a local file instead (see FileTransport), call CloseFileTransports
+// before exiting
 func NewBaseReport(customerGUID, reporter, eventReceiverUrl string, httpClient httputils.IHttpClient) *BaseReport {
-	return &BaseReport{
+	report := &BaseReport{
 		CustomerGUID:     customerGUID,
 		Reporter:         reporter,
 		Status:           JobStarted,
@@ -148,6 +202,15 @@ func NewBaseReport(customerGUID, reporter, eventReceiverUrl string, httpClient h
 		eventReceiverUrl: eventReceiverUrl,
 		httpClient:       httpClient,
 	}
+	if strings.HasPrefix(eventReceiverUrl, fileTransportScheme) {
+		transport, err := sharedFileTransport(eventReceiverUrl)
+		if err != nil {
+			report.transport = &failedTransport{err: err}
+		} else {
+			report.transport = transport
+		}
+	}
+	return report
 }
 
 // IReporter reporter interface
@@ -196,13 +259,13 @@ type IReporter interface {
 	// set methods
 	SendAction(action string, sendReport bool, errChan chan<- error)
 	SendError(err error, sendReport bool, initErrors bool, errChan chan<- error)
-	SendStatus(status string, sendReport bool, errChan chan<- error)
+	SendStatus(status StatusType, sendReport bool, errChan chan<- error)
 	SendDetails(details string, sendReport bool, errChan chan<- error)
 	SendWarning(warning string, sendReport bool, initWarnings bool, errChan chan<- error)
 
 	// set methods
 	SetReporter(string)
-	SetStatus(string)
+	SetStatus(StatusType)
 	SetActionName(string)
 	SetTarget(string)
 	SetActionID(string)
@@ -215,7 +278,7 @@ type IReporter interface {
 
 	// get methods
 	GetReporter() string
-	GetStatus() string
+	GetStatus() StatusType
 	GetActionName() string
 	GetTarget() string
 	GetErrorList() []string
@@ -228,10 +291,47 @@ type IReporter interface {
 	GetDetails() string
 }
 
+// IContextReporter an IReporter whose sends are aborted (with ctx.Err()) once ctx is done, implemented by BaseReport
+type IContextReporter interface {
+	IReporter
+
+	// SendContext - same as Send, aborted (with ctx.Err()) once ctx is done
+	SendContext(ctx context.Context) (int, string, error)
+	SendAsRoutineContext(context.Context, bool, chan<- error)
+
+	// context aware set methods - the report (if sent) is aborted once ctx is done
+	SendActionContext(ctx context.Context, action string, sendReport bool, errChan chan<- error)
+	SendErrorContext(ctx context.Context, err error, sendReport bool, initErrors bool, errChan chan<- error)
+	SendStatusContext(ctx context.Context, status StatusType, sendReport bool, errChan chan<- error)
+	SendDetailsContext(ctx context.Context, details string, sendReport bool, errChan chan<- error)
+	SendWarningContext(ctx context.Context, warning string, sendReport bool, initWarnings bool, errChan chan<- error)
+}
+
+var _ IContextReporter = (*BaseReport)(nil)
+
+// ITraceReporter an IReporter carrying a W3C trace context (see TraceContext), implemented by BaseReport
+type ITraceReporter interface {
+	IReporter
+
+	SetTraceContext(TraceContext)
+	GetTraceContext() TraceContext
+}
+
+var _ ITraceReporter = (*BaseReport)(nil)
+
+// IErrorDetailsReporter an IReporter keeping structured error details (see ErrorDetail), implemented by BaseReport
+type IErrorDetailsReporter interface {
+	IReporter
+
+	GetErrorDetails() []ErrorDetail
+}
+
+var _ IErrorDetailsReporter = (*BaseReport)(nil)
+
 // IsEqual are two IReporter objects equal
 func IsEqual(lhs, rhs IReporter) bool {
 	if strings.Compare(lhs.GetJobID(), rhs.GetJobID()) != 0 ||
-		strings.Compare(lhs.GetStatus(), rhs.GetStatus()) != 0 ||
+		lhs.GetStatus() != rhs.GetStatus() ||
 		strings.Compare(lhs.GetReporter(), rhs.GetReporter()) != 0 ||
 		strings.Compare(lhs.GetTarget(), rhs.GetTarget()) != 0 ||
 		strings.Compare(lhs.GetActionID(), rhs.GetActionID()) != 0 ||
@@ -1,6 +1,8 @@
 package datastructures
 
 //BaseReportMock : represents the basic reports from various actions eg. attach and so on
+//
+// Deprecated: BaseReportMock does not implement IReporter, use RecordingReporter
 type BaseReportMock struct {
 	BaseReport `json:",inline"`
 }
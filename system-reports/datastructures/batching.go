@@ -0,0 +1,362 @@
+package datastructures
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/armosec/utils-go/httputils"
+)
+
+const (
+	defaultSystemReportBulkEndpoint = "/k8s/sysreport/bulk"
+	defaultMaxBatchSize             = 100
+	defaultBatchFlushInterval       = time.Second
+	defaultBatchCloseTimeout        = 10 * time.Second
+)
+
+// errBatchingUnavailable is returned to reports that should be sent on their own, since the server rejected the bulk format
+// or the BatchingSender was closed
+var errBatchingUnavailable = errors.New("bulk sending is unavailable")
+
+// BulkItemResult the result of a single report in a bulk request. The bulk endpoint answers with a JSON array of these,
+// in the order of the reports in the request
+type BulkItemResult struct {
+	Status int    `json:"status"` // http status of the single report
+	Body   string `json:"body"`   // jobID for the first report of a job, "ok" from the 2nd report onwards
+}
+
+// BulkCallback is called with the result of a report sent in bulk, the same values Send returns
+type BulkCallback func(status int, body string, err error)
+
+// BatchingConfig BatchingSender configuration
+type BatchingConfig struct {
+	EventReceiverUrl string                // event receiver url
+	Endpoint         string                // bulk endpoint path, default "/k8s/sysreport/bulk"
+	HttpClient       httputils.IHttpClient // http client
+	MaxBatchSize     int                   // a batch is sent once it has that many reports, default 100
+	FlushInterval    time.Duration         // a batch is sent at the latest this long after its first report, default 1s
+	RetryPolicy      RetryPolicy           // retry policy of the bulk request, default follows MAX_RETRIES and RETRY_DELAY
+	Authenticator    Authenticator         // authenticates the bulk requests, default the authenticator of the reports of the batch
+	CloseTimeout     time.Duration         // how long Close waits for the batches in flight before aborting them, default 10s
+}
+
+/*
+BatchingSender collects reports from many BaseReports and posts them together as a JSON array to the bulk endpoint.
+
+Reports using it (see BaseReport.SetBatchingSender) still get their own result - status, jobID/"ok" and error - on their errChan.
+If the server rejects the bulk format the sender falls back to sending every report on its own.
+
+Unless BatchingConfig.Authenticator is set, reports of different authenticators are posted in separate requests, each
+signed by the authenticator of its reports. Reports of different trace contexts are posted in separate requests too, each
+with its own traceparent header.
+*/
+type BatchingSender struct {
+	config      BatchingConfig
+	mu          sync.Mutex
+	pending     []batchItem
+	timer       *time.Timer
+	unsupported bool
+	closed      bool
+	inflight    sync.WaitGroup
+	ctx         context.Context // canceled by Close, aborts the requests and retries in flight
+	cancel      context.CancelFunc
+}
+
+type batchItem struct {
+	report        json.RawMessage
+	callback      BulkCallback
+	authenticator Authenticator
+	traceContext  TraceContext
+	ctx           context.Context // the report is not posted once ctx is done, nil for reports of Enqueue
+}
+
+// NewBatchingSender returns a BatchingSender, call Close to send the reports left before exiting
+func NewBatchingSender(config BatchingConfig) *BatchingSender {
+	if config.Endpoint == "" {
+		config.Endpoint = defaultSystemReportBulkEndpoint
+	}
+	if config.MaxBatchSize <= 0 {
+		config.MaxBatchSize = defaultMaxBatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultBatchFlushInterval
+	}
+	if config.CloseTimeout <= 0 {
+		config.CloseTimeout = defaultBatchCloseTimeout
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &BatchingSender{config: config, ctx: ctx, cancel: cancel}
+}
+
+// Enqueue adds a serialized report to the current batch, callback is called once the batch was sent
+func (b *BatchingSender) Enqueue(report json.RawMessage, callback BulkCallback) {
+	b.enqueue(batchItem{report: report, callback: callback})
+}
+
+func (b *BatchingSender) enqueue(item batchItem) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.unsupported || b.closed {
+		go item.callback(500, "", errBatchingUnavailable)
+		return
+	}
+	b.pending = append(b.pending, item)
+	if len(b.pending) >= b.config.MaxBatchSize {
+		b.unprotectedFlush()
+		return
+	}
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.config.FlushInterval, b.Flush)
+	}
+}
+
+// Flush sends the current batch without waiting for the size or time threshold
+func (b *BatchingSender) Flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.unprotectedFlush()
+}
+
+/*
+Close sends the current batch and waits for all the batches in flight, reports enqueued after Close are sent on their own.
+Batches still in flight after BatchingConfig.CloseTimeout are aborted, their reports fail with context.Canceled
+*/
+func (b *BatchingSender) Close() {
+	b.mu.Lock()
+	b.closed = true
+	b.unprotectedFlush()
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.inflight.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(b.config.CloseTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		b.cancel()
+		<-done
+	}
+	b.cancel()
+}
+
+func (b *BatchingSender) unprotectedFlush() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.pending) == 0 {
+		return
+	}
+	batch := b.pending
+	b.pending = nil
+	b.inflight.Add(1)
+	go func() {
+		defer b.inflight.Done()
+		b.sendBatch(batch)
+	}()
+}
+
+// sendBatch posts the batch, a request per trace context (and per authenticator unless BatchingConfig.Authenticator is
+// set), and hands every report its own result
+func (b *BatchingSender) sendBatch(batch []batchItem) {
+	groups := [][]batchItem{}
+	for _, item := range batch {
+		i := 0
+		for ; i < len(groups); i++ {
+			if b.sameGroup(groups[i][0], item) {
+				break
+			}
+		}
+		if i == len(groups) {
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], item)
+	}
+	for _, group := range groups {
+		b.sendGroup(group)
+	}
+}
+
+// sameGroup returns true if the reports can be posted in the same request
+func (b *BatchingSender) sameGroup(a, other batchItem) bool {
+	if a.traceContext != other.traceContext {
+		return false
+	}
+	return b.config.Authenticator != nil || sameAuthenticator(a.authenticator, other.authenticator)
+}
+
+// sameAuthenticator returns true if both authenticators are deeply equal, eg. two APIKeyAuthenticator of the same key
+func sameAuthenticator(a, other Authenticator) bool {
+	return reflect.DeepEqual(a, other)
+}
+
+func (b *BatchingSender) sendGroup(batch []batchItem) {
+	results, batch, err := b.postBatch(batch)
+	if err != nil {
+		status := 500
+		var statusErr *bulkStatusError
+		if errors.As(err, &statusErr) {
+			status = statusErr.status
+		}
+		for i := range batch {
+			batch[i].callback(status, err.Error(), err)
+		}
+		return
+	}
+	for i := range batch {
+		result := results[i]
+		if result.Status < 200 || result.Status >= 300 {
+			batch[i].callback(result.Status, result.Body, fmt.Errorf("failed to send report. Status: %d Body:%s", result.Status, result.Body))
+			continue
+		}
+		batch[i].callback(result.Status, result.Body, nil)
+	}
+}
+
+type bulkStatusError struct {
+	status int
+	body   string
+}
+
+func (e *bulkStatusError) Error() string {
+	return fmt.Sprintf("failed posting bulk reports. Status: %d Body:%s", e.status, e.body)
+}
+
+// dropCanceled hands the reports whose send was canceled their ctx error, and returns the reports left to post
+func dropCanceled(batch []batchItem) []batchItem {
+	left := batch[:0]
+	for _, item := range batch {
+		if item.ctx != nil && item.ctx.Err() != nil {
+			item.callback(500, "report was not sent in bulk", item.ctx.Err())
+			continue
+		}
+		left = append(left, item)
+	}
+	return left
+}
+
+// postBatch posts the reports of the batch that were not canceled, and returns their results and the posted reports
+func (b *BatchingSender) postBatch(batch []batchItem) ([]BulkItemResult, []batchItem, error) {
+	retryPolicy := b.config.RetryPolicy
+	if retryPolicy == nil {
+		retryPolicy = defaultRetryPolicy()
+	}
+	authenticator := b.config.Authenticator
+	if authenticator == nil {
+		authenticator = batch[0].authenticator // the reports of a batch share their authenticator, see sendBatch
+	}
+	url := b.config.EventReceiverUrl + b.config.Endpoint
+	for attempt := 1; ; attempt++ {
+		if err := b.ctx.Err(); err != nil {
+			return nil, batch, err
+		}
+		// a report canceled while waiting for its batch (or for a retry) is not posted, its sender was told it failed
+		if batch = dropCanceled(batch); len(batch) == 0 {
+			return nil, batch, nil
+		}
+		reqBody := bytes.Buffer{}
+		reqBody.WriteByte('[')
+		for i := range batch {
+			if i > 0 {
+				reqBody.WriteByte(',')
+			}
+			reqBody.Write(batch[i].report)
+		}
+		reqBody.WriteByte(']')
+		headers, err := authenticatedHeaders(b.ctx, authenticator, http.MethodPost, url, reqBody.Bytes())
+		if err != nil {
+			return nil, batch, err
+		}
+		if tc := batch[0].traceContext; tc.IsValid() {
+			headers[TraceParentHeader] = tc.TraceParent()
+			if tc.TraceState != "" {
+				headers[TraceStateHeader] = tc.TraceState
+			}
+		}
+		resp, err := httputils.HttpPostWithContext(b.ctx, b.config.HttpClient, url, headers, reqBody.Bytes())
+		statusCode := 0
+		var retryAfter time.Duration
+		if err == nil {
+			statusCode = resp.StatusCode
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			body, _ := httputils.HttpRespToString(resp)
+			if statusCode >= 200 && statusCode < 300 {
+				results, err := b.parseResults(body, len(batch))
+				return results, batch, err
+			}
+			if isBulkRejected(statusCode) {
+				return nil, batch, b.markUnsupported()
+			}
+			err = &bulkStatusError{status: statusCode, body: body}
+		}
+		if b.ctx.Err() != nil {
+			return nil, batch, b.ctx.Err()
+		}
+		delay, ok := retryPolicy.NextRetry(attempt, statusCode, retryAfter, err)
+		if !ok {
+			return nil, batch, err
+		}
+		if !sleepContext(b.ctx, delay) {
+			return nil, batch, b.ctx.Err()
+		}
+	}
+}
+
+// parseResults parses the bulk response, a response that is not a result per report means the server does not know the bulk format
+func (b *BatchingSender) parseResults(body string, count int) ([]BulkItemResult, error) {
+	results := []BulkItemResult{}
+	if err := json.Unmarshal([]byte(body), &results); err != nil || len(results) != count {
+		return nil, b.markUnsupported()
+	}
+	return results, nil
+}
+
+func (b *BatchingSender) markUnsupported() error {
+	b.mu.Lock()
+	b.unsupported = true
+	b.mu.Unlock()
+	return errBatchingUnavailable
+}
+
+// isBulkRejected returns true for statuses meaning the server has no bulk endpoint or does not accept a JSON array.
+// A 400 is not one of them, it rejects the reports of that batch only
+func isBulkRejected(statusCode int) bool {
+	switch statusCode {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusUnsupportedMediaType, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+// send enqueues the report and waits for its result or for ctx to be done. The batch is sent with the trace context of
+// ctx and, unless BatchingConfig.Authenticator is set, with the authenticator of the report. A report whose ctx is done
+// is left out of its batch, unless the batch was already posted
+func (b *BatchingSender) send(ctx context.Context, report json.RawMessage, authenticator Authenticator) (int, string, error) {
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	resultChan := make(chan result, 1)
+	traceContext, _ := TraceContextFromContext(ctx)
+	b.enqueue(batchItem{report: report, authenticator: authenticator, traceContext: traceContext, ctx: ctx, callback: func(status int, body string, err error) {
+		resultChan <- result{status: status, body: body, err: err}
+	}})
+	select {
+	case <-ctx.Done():
+		return 500, "report is still waiting to be sent in bulk", ctx.Err()
+	case r := <-resultChan:
+		return r.status, r.body, r.err
+	}
+}
@@ -0,0 +1,112 @@
+package datastructures
+
+import (
+	"errors"
+	"time"
+
+	"github.com/francoispqt/gojay"
+)
+
+// ErrorSeverity severity of an error entry
+type ErrorSeverity string
+
+const (
+	SeverityError   ErrorSeverity = "error"
+	SeverityWarning ErrorSeverity = "warning"
+)
+
+// ErrorDetail a structured error entry, sent in "errorDetails" alongside the legacy "errors" strings
+type ErrorDetail struct {
+	ActionName string        `json:"action"`          // the action the error occurred in
+	ActionID   string        `json:"actionID"`        // actionID of that action
+	Severity   ErrorSeverity `json:"severity"`        // error/warning
+	Message    string        `json:"message"`         // the error message
+	Code       string        `json:"code,omitempty"`  // code of the first CodedError in the error chain
+	Chain      []string      `json:"chain,omitempty"` // messages of the wrapped errors (errors.Unwrap chain), outermost first
+	Timestamp  time.Time     `json:"timestamp"`
+}
+
+// CodedError an error with a machine readable code, see WithErrorCode
+type CodedError interface {
+	error
+	ErrorCode() string
+}
+
+type codedError struct {
+	code string
+	err  error
+}
+
+func (e *codedError) Error() string     { return e.err.Error() }
+func (e *codedError) ErrorCode() string { return e.code }
+func (e *codedError) Unwrap() error     { return e.err }
+
+// WithErrorCode attaches a code to the error, SendError reports it in the error details
+func WithErrorCode(err error, code string) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{code: code, err: err}
+}
+
+// newErrorDetail builds the error entry of err. The Unwrap chain is kept only when it adds information to the message
+func newErrorDetail(actionName, actionID string, severity ErrorSeverity, err error) ErrorDetail {
+	detail := ErrorDetail{
+		ActionName: actionName,
+		ActionID:   actionID,
+		Severity:   severity,
+		Message:    err.Error(),
+		Timestamp:  time.Now(),
+	}
+	var coded CodedError
+	if errors.As(err, &coded) {
+		detail.Code = coded.ErrorCode()
+	}
+	for wrapped := errors.Unwrap(err); wrapped != nil; wrapped = errors.Unwrap(wrapped) {
+		if _, ok := wrapped.(*codedError); ok {
+			continue
+		}
+		detail.Chain = append(detail.Chain, wrapped.Error())
+	}
+	return detail
+}
+
+// ======================================== GOJAY ========================================
+
+func (detail *ErrorDetail) UnmarshalJSONObject(dec *gojay.Decoder, key string) (err error) {
+	switch key {
+	case "action":
+		err = dec.String(&(detail.ActionName))
+	case "actionID":
+		err = dec.String(&(detail.ActionID))
+	case "severity":
+		var severity string
+		err = dec.String(&severity)
+		detail.Severity = ErrorSeverity(severity)
+	case "message":
+		err = dec.String(&(detail.Message))
+	case "code":
+		err = dec.String(&(detail.Code))
+	case "chain":
+		err = dec.SliceString(&(detail.Chain))
+	case "timestamp":
+		err = dec.Time(&(detail.Timestamp), time.RFC3339)
+		detail.Timestamp = detail.Timestamp.Local()
+	}
+	return err
+}
+
+func (detail *ErrorDetail) NKeys() int {
+	return 0
+}
+
+type errorDetailsList []ErrorDetail
+
+func (list *errorDetailsList) UnmarshalJSONArray(dec *gojay.Decoder) error {
+	detail := ErrorDetail{}
+	if err := dec.Object(&detail); err != nil {
+		return err
+	}
+	*list = append(*list, detail)
+	return nil
+}
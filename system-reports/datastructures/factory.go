@@ -0,0 +1,182 @@
+package datastructures
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/armosec/utils-go/httputils"
+)
+
+// ReporterOption configures a ReporterFactory
+type ReporterOption func(*ReporterFactory)
+
+// WithEventReceiverURL the event receiver url, a "file://" url writes the reports to a local file (see FileTransport)
+func WithEventReceiverURL(eventReceiverUrl string) ReporterOption {
+	return func(f *ReporterFactory) { f.eventReceiverUrl = eventReceiverUrl }
+}
+
+// WithEndpoint the path the reports are posted to, default the global endpoint (see SetDefaultEndpoint)
+func WithEndpoint(endpoint string) ReporterOption {
+	return func(f *ReporterFactory) { f.endpoint = endpoint }
+}
+
+// WithHttpClient the http client of the reports, default a client of the factory (so the reports share its connection pool)
+func WithHttpClient(httpClient httputils.IHttpClient) ReporterOption {
+	return func(f *ReporterFactory) { f.httpClient = httpClient }
+}
+
+// WithRetryPolicy the retry policy of the reports, default follows MAX_RETRIES and RETRY_DELAY
+func WithRetryPolicy(retryPolicy RetryPolicy) ReporterOption {
+	return func(f *ReporterFactory) { f.retryPolicy = retryPolicy }
+}
+
+// WithTransport the transport of the reports, instead of posting them to the event receiver
+func WithTransport(transport Transport) ReporterOption {
+	return func(f *ReporterFactory) { f.transport = transport }
+}
+
+// WithLogger the logger of the reports, default the global logger
+func WithLogger(logger Logger) ReporterOption {
+	return func(f *ReporterFactory) { f.logger = logger }
+}
+
+// WithAuthenticator authenticates the requests of the reports to the event receiver
+func WithAuthenticator(authenticator Authenticator) ReporterOption {
+	return func(f *ReporterFactory) { f.authenticator = authenticator }
+}
+
+// WithRedactor redacts the reports before they are sent, as well as their errors and logs (see BaseReport.SetRedactor)
+func WithRedactor(redactor *Redactor) ReporterOption {
+	return func(f *ReporterFactory) { f.redactor = redactor }
+}
+
+// WithCustomerGUID the customerGUID of the reports
+func WithCustomerGUID(customerGUID string) ReporterOption {
+	return func(f *ReporterFactory) { f.customerGUID = customerGUID }
+}
+
+// WithTarget the default target of the reports
+func WithTarget(target string) ReporterOption {
+	return func(f *ReporterFactory) { f.target = target }
+}
+
+// WithLabels the default labels of the reports, merged with the labels of former WithLabels options
+func WithLabels(labels map[string]string) ReporterOption {
+	return func(f *ReporterFactory) {
+		if f.labels == nil {
+			f.labels = map[string]string{}
+		}
+		for k, v := range labels {
+			f.labels[k] = v
+		}
+	}
+}
+
+/*
+ReporterFactory creates BaseReports sharing the same configuration - event receiver url and endpoint, http client (and
+its connection pool), retry policy, transport, logger, authenticator, redactor, default target and labels. Factories are independent of each
+other, so reports to different event receivers or endpoints can be created side by side
+
+	factory, err := datastructures.NewReporterFactory(
+		datastructures.WithEventReceiverURL("https://report.armo.cloud"),
+		datastructures.WithCustomerGUID(customerGUID),
+		datastructures.WithLabels(map[string]string{"cluster": clusterName}),
+	)
+	reporter := factory.NewReporter("my-reporter")
+*/
+type ReporterFactory struct {
+	eventReceiverUrl string
+	endpoint         string
+	httpClient       httputils.IHttpClient
+	retryPolicy      RetryPolicy
+	transport        Transport
+	logger           Logger
+	authenticator    Authenticator
+	redactor         *Redactor
+	customerGUID     string
+	target           string
+	labels           map[string]string
+}
+
+// NewReporterFactory returns a factory configured with the options, an event receiver url or a transport is required
+func NewReporterFactory(options ...ReporterOption) (*ReporterFactory, error) {
+	f := &ReporterFactory{}
+	for _, option := range options {
+		option(f)
+	}
+	if f.transport == nil {
+		if err := f.setDefaultTransport(); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateEndpoint(f.endpoint); err != nil {
+		return nil, err
+	}
+	if f.httpClient == nil {
+		f.httpClient = &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}
+	}
+	return f, nil
+}
+
+func (f *ReporterFactory) setDefaultTransport() error {
+	if f.eventReceiverUrl == "" {
+		return fmt.Errorf("either an event receiver url or a transport is required")
+	}
+	if strings.HasPrefix(f.eventReceiverUrl, fileTransportScheme) {
+		transport, err := sharedFileTransport(f.eventReceiverUrl)
+		if err != nil {
+			return err
+		}
+		f.transport = transport
+		return nil
+	}
+	u, err := url.Parse(f.eventReceiverUrl)
+	if err != nil {
+		return fmt.Errorf("invalid event receiver url '%s': %w", f.eventReceiverUrl, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid event receiver url '%s', expected http(s)://<host>", f.eventReceiverUrl)
+	}
+	return nil
+}
+
+// NewReporter returns a new report of the reporter, with the configuration of the factory
+func (f *ReporterFactory) NewReporter(reporter string) *BaseReport {
+	report := &BaseReport{
+		CustomerGUID:     f.customerGUID,
+		Reporter:         reporter,
+		Target:           f.target,
+		Status:           JobStarted,
+		ActionName:       fmt.Sprintf("Starting %s", reporter),
+		ActionID:         "1",
+		ActionIDN:        1,
+		Labels:           copyLabels(f.labels),
+		eventReceiverUrl: f.eventReceiverUrl,
+		endpoint:         f.endpoint,
+		httpClient:       f.httpClient,
+		transport:        f.transport,
+		retryPolicy:      f.retryPolicy,
+		logger:           f.logger,
+		authenticator:    f.authenticator,
+		redactor:         f.redactor,
+	}
+	return report
+}
+
+// URL returns the full url the reports of the factory are posted to
+func (f *ReporterFactory) URL() string {
+	return (&HTTPTransport{EventReceiverUrl: f.eventReceiverUrl, Endpoint: f.endpoint}).URL()
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	if len(labels) == 0 {
+		return nil
+	}
+	labelsCopy := make(map[string]string, len(labels))
+	for k, v := range labels {
+		labelsCopy[k] = v
+	}
+	return labelsCopy
+}
@@ -0,0 +1,326 @@
+package datastructures
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const fileTransportScheme = "file://"
+
+// FsyncPolicy when the report file is synced to disk
+type FsyncPolicy string
+
+const (
+	FsyncAlways   FsyncPolicy = "always" // after every report
+	FsyncOnRotate FsyncPolicy = "rotate" // before a segment is rotated or the file closed
+	FsyncNever    FsyncPolicy = "never"  // left to the OS
+)
+
+var (
+	fileTransports   = map[string]*FileTransport{}
+	fileTransportsMu sync.Mutex
+)
+
+// FileTransportConfig FileTransport configuration
+type FileTransportConfig struct {
+	Path     string        // path of the active report file, rotated segments are created next to it
+	MaxSize  int64         // the file is rotated once it reaches this size in bytes, 0 for no size limit
+	MaxAge   time.Duration // the file is rotated once it was open for this long, 0 for no age limit
+	Compress bool          // gzip rotated segments
+	Fsync    FsyncPolicy   // default FsyncOnRotate
+}
+
+/*
+FileTransport appends every report as a single JSON line to a local file, to be collected later (eg. by a sidecar) in
+offline and air-gapped clusters.
+
+There is no event receiver to assign jobIDs, so the first report of a job gets a generated jobID written into its line.
+Select it in NewBaseReport using a "file://" url instead of the event receiver url, eg.
+
+	file:///var/log/armo/sysreports.jsonl?maxSize=10485760&maxAge=24h&compress=true&fsync=always
+
+The reports created with the same file url share its FileTransport. With the default FsyncOnRotate policy the last reports
+may only be synced on close, so call CloseFileTransports as a shutdown step before exiting.
+*/
+type FileTransport struct {
+	config   FileTransportConfig
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// NewFileTransport returns a FileTransport, the file is opened on the first report
+func NewFileTransport(config FileTransportConfig) *FileTransport {
+	if config.Fsync == "" {
+		config.Fsync = FsyncOnRotate
+	}
+	return &FileTransport{config: config}
+}
+
+// ParseFileTransportUrl parses a "file://<path>?maxSize=<bytes>&maxAge=<duration>&compress=<bool>&fsync=<policy>" url
+func ParseFileTransportUrl(fileUrl string) (FileTransportConfig, error) {
+	config := FileTransportConfig{}
+	if !strings.HasPrefix(fileUrl, fileTransportScheme) {
+		return config, fmt.Errorf("'%s' is not a file url", fileUrl)
+	}
+	u, err := url.Parse(fileUrl)
+	if err != nil {
+		return config, fmt.Errorf("invalid file url '%s': %w", fileUrl, err)
+	}
+	config.Path = u.Host + u.Path
+	if config.Path == "" {
+		return config, fmt.Errorf("file url '%s' has no path", fileUrl)
+	}
+	query := u.Query()
+	if v := query.Get("maxSize"); v != "" {
+		if config.MaxSize, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return config, fmt.Errorf("invalid maxSize '%s': %w", v, err)
+		}
+	}
+	if v := query.Get("maxAge"); v != "" {
+		if config.MaxAge, err = time.ParseDuration(v); err != nil {
+			return config, fmt.Errorf("invalid maxAge '%s': %w", v, err)
+		}
+	}
+	if v := query.Get("compress"); v != "" {
+		if config.Compress, err = strconv.ParseBool(v); err != nil {
+			return config, fmt.Errorf("invalid compress '%s': %w", v, err)
+		}
+	}
+	switch policy := FsyncPolicy(query.Get("fsync")); policy {
+	case "", FsyncAlways, FsyncOnRotate, FsyncNever:
+		config.Fsync = policy
+	default:
+		return config, fmt.Errorf("invalid fsync policy '%s'", policy)
+	}
+	return config, nil
+}
+
+// sharedFileTransport returns the FileTransport of the url, reports using the same url share the same file
+func sharedFileTransport(fileUrl string) (*FileTransport, error) {
+	fileTransportsMu.Lock()
+	defer fileTransportsMu.Unlock()
+	if transport, ok := fileTransports[fileUrl]; ok {
+		return transport, nil
+	}
+	config, err := ParseFileTransportUrl(fileUrl)
+	if err != nil {
+		return nil, err
+	}
+	transport := NewFileTransport(config)
+	fileTransports[fileUrl] = transport
+	return transport, nil
+}
+
+// CloseFileTransports syncs and closes the files of the transports created from file urls (see NewBaseReport), it should
+// be called before exiting. A report sent afterwards opens its file again
+func CloseFileTransports() error {
+	fileTransportsMu.Lock()
+	defer fileTransportsMu.Unlock()
+	var errs []error
+	for _, transport := range fileTransports {
+		if err := transport.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t *FileTransport) Deliver(ctx context.Context, report []byte) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	line, jobID, err := withJobID(report)
+	if err != nil {
+		return Result{}, err
+	}
+	line = append(line, '\n')
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.file != nil && t.shouldRotate(int64(len(line))) {
+		if err := t.rotate(); err != nil {
+			return Result{}, err
+		}
+	}
+	if t.file == nil {
+		if err := t.open(); err != nil {
+			return Result{}, err
+		}
+	}
+	n, err := t.file.Write(line)
+	t.size += int64(n)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to write report to '%s': %w", t.config.Path, err)
+	}
+	if t.config.Fsync == FsyncAlways {
+		if err := t.file.Sync(); err != nil {
+			return Result{}, fmt.Errorf("failed to sync '%s': %w", t.config.Path, err)
+		}
+	}
+	if jobID != "" {
+		return Result{StatusCode: 200, Body: jobID}, nil
+	}
+	return Result{StatusCode: 200, Body: "ok"}, nil
+}
+
+func (t *FileTransport) String() string {
+	return fileTransportScheme + t.config.Path
+}
+
+// Close syncs (unless the policy is FsyncNever) and closes the file
+func (t *FileTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.close()
+}
+
+func (t *FileTransport) open() error {
+	if err := os.MkdirAll(filepath.Dir(t.config.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory of '%s': %w", t.config.Path, err)
+	}
+	f, err := os.OpenFile(t.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open '%s': %w", t.config.Path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat '%s': %w", t.config.Path, err)
+	}
+	t.file = f
+	t.size = info.Size()
+	t.openedAt = time.Now()
+	if t.size > 0 {
+		t.openedAt = info.ModTime()
+	}
+	return nil
+}
+
+func (t *FileTransport) close() error {
+	if t.file == nil {
+		return nil
+	}
+	var err error
+	if t.config.Fsync != FsyncNever {
+		err = t.file.Sync()
+	}
+	if closeErr := t.file.Close(); err == nil {
+		err = closeErr
+	}
+	t.file = nil
+	return err
+}
+
+func (t *FileTransport) shouldRotate(lineSize int64) bool {
+	if t.size == 0 {
+		return false
+	}
+	if t.config.MaxSize > 0 && t.size+lineSize > t.config.MaxSize {
+		return true
+	}
+	return t.config.MaxAge > 0 && time.Since(t.openedAt) >= t.config.MaxAge
+}
+
+// rotate renames the current file to a timestamped segment (compressed if configured), the next report opens a new file
+func (t *FileTransport) rotate() error {
+	if err := t.close(); err != nil {
+		return fmt.Errorf("failed to close '%s' before rotation: %w", t.config.Path, err)
+	}
+	segment := fmt.Sprintf("%s.%s", t.config.Path, time.Now().UTC().Format("20060102T150405.000000000"))
+	if err := os.Rename(t.config.Path, segment); err != nil {
+		return fmt.Errorf("failed to rotate '%s': %w", t.config.Path, err)
+	}
+	if t.config.Compress {
+		if err := gzipFile(segment); err != nil {
+			return fmt.Errorf("failed to compress rotated segment '%s': %w", segment, err)
+		}
+	}
+	return nil
+}
+
+// gzipFile replaces the file with a gzipped <file>.gz
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(path)
+	_, err = io.Copy(zw, src)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = dst.Sync()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
+
+// withJobID returns the report as a compact JSON line. A report without a jobID is given a generated one,
+// which is returned as well
+func withJobID(report []byte) ([]byte, string, error) {
+	header := struct {
+		JobID string `json:"jobID"`
+	}{}
+	if err := json.Unmarshal(report, &header); err != nil {
+		return nil, "", fmt.Errorf("failed to decode report: %w", err)
+	}
+	if header.JobID != "" {
+		compact := bytes.Buffer{}
+		if err := json.Compact(&compact, report); err != nil {
+			return nil, "", err
+		}
+		return compact.Bytes(), "", nil
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(report, &fields); err != nil {
+		return nil, "", fmt.Errorf("failed to decode report: %w", err)
+	}
+	jobID, err := NewJobID()
+	if err != nil {
+		return nil, "", err
+	}
+	fields["jobID"], _ = json.Marshal(jobID)
+	line, err := json.Marshal(fields)
+	return line, jobID, err
+}
+
+// NewJobID returns a random (version 4) UUID, as the event receiver assigns to new jobs
+func NewJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate jobID: %w", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	h := hex.EncodeToString(b)
+	return fmt.Sprintf("%s-%s-%s-%s-%s", h[0:8], h[8:12], h[12:16], h[16:20], h[20:]), nil
+}
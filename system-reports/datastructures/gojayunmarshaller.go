@@ -16,7 +16,9 @@ func (reporter *BaseReport) UnmarshalJSONObject(dec *gojay.Decoder, key string)
 	case "target":
 		err = dec.String(&(reporter.Target))
 	case "status":
-		err = dec.String(&(reporter.Status))
+		var status string
+		err = dec.String(&status)
+		reporter.Status = StatusType(status)
 	case "actionID":
 		err = dec.String(&(reporter.ActionID))
 	case "jobID":
@@ -25,19 +27,44 @@ func (reporter *BaseReport) UnmarshalJSONObject(dec *gojay.Decoder, key string)
 		err = dec.String(&(reporter.ActionName))
 	case "parentAction":
 		err = dec.String(&(reporter.ParentAction))
+	case "details":
+		err = dec.String(&(reporter.Details))
 	case "numSeq":
 
 		err = dec.Int(&(reporter.ActionIDN))
 
 	case "errors":
 		err = dec.SliceString(&(reporter.Errors))
+	case "errorDetails":
+		details := errorDetailsList{}
+		err = dec.Array(&details)
+		reporter.ErrorDetails = details
 
 	case "customerGUID":
 		err = dec.String(&(reporter.CustomerGUID))
+	case "labels":
+		labels := labelsMap{}
+		err = dec.Object(labels)
+		reporter.Labels = labels
 	}
 	return err
 }
 
+type labelsMap map[string]string
+
+func (labels labelsMap) UnmarshalJSONObject(dec *gojay.Decoder, key string) error {
+	var value string
+	if err := dec.String(&value); err != nil {
+		return err
+	}
+	labels[key] = value
+	return nil
+}
+
+func (labels labelsMap) NKeys() int {
+	return 0
+}
+
 func (ae *BaseReport) NKeys() int {
 	return 0
 }
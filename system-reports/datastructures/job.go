@@ -0,0 +1,284 @@
+package datastructures
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+/*
+Job drives a BaseReport as part of a tree of jobs, eg. autoattach (the parent job) and the attach of every workload
+(child jobs). Unlike the Send* methods of BaseReport, the Job methods send synchronously and return the error.
+
+	job := NewJob(NewBaseReport(customerGUID, "autoattach", eventReceiverUrl, httpClient))
+	job.Start(ctx)
+	child, _ := job.StartChild(ctx, "attach", wlid)
+	child.Finish(ctx, JobSuccess, false)
+	job.Finish(ctx, JobDone, true)
+*/
+type Job struct {
+	report *BaseReport
+	parent *Job
+
+	started  chan struct{} // closed once the first report was sent (or failed to)
+	once     sync.Once
+	startErr error
+
+	mu          sync.Mutex
+	children    []*Job
+	running     sync.WaitGroup // children that did not finish yet
+	finished    bool
+	finalStatus StatusType
+}
+
+// NewJob returns a root job reporting with the report. A report that already has a jobID (eg. from annotations) is
+// considered started, its children do not wait for a jobID
+func NewJob(report *BaseReport) *Job {
+	job := newJob(report, nil)
+	if report.GetJobID() != "" {
+		job.markStarted(nil)
+	}
+	return job
+}
+
+func newJob(report *BaseReport, parent *Job) *Job {
+	return &Job{report: report, parent: parent, started: make(chan struct{})}
+}
+
+// Report returns the report of the job
+func (job *Job) Report() *BaseReport {
+	return job.report
+}
+
+// Parent returns the parent job, nil for a root job
+func (job *Job) Parent() *Job {
+	return job.parent
+}
+
+// Children returns the child jobs in the order they were started
+func (job *Job) Children() []*Job {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	children := make([]*Job, len(job.children))
+	copy(children, job.children)
+	return children
+}
+
+// WaitJobID waits for the jobID the event receiver assigns on the first report
+func (job *Job) WaitJobID(ctx context.Context) (string, error) {
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-job.started:
+	}
+	if job.startErr != nil {
+		return "", job.startErr
+	}
+	return job.report.GetJobID(), nil
+}
+
+// Start sends the "started" report. A child job first waits for the jobID of its parent and sets it as its ParentAction
+func (job *Job) Start(ctx context.Context) error {
+	if job.parent != nil {
+		parentJobID, err := job.parent.WaitJobID(ctx)
+		if err != nil {
+			err = fmt.Errorf("parent job was not started: %w", err)
+			job.markStarted(err)
+			return err
+		}
+		job.report.SetParentAction(parentJobID)
+	}
+	job.report.SetStatus(JobStarted)
+	err := job.send(ctx)
+	if err == nil && job.report.GetJobID() == "" {
+		err = fmt.Errorf("no jobID was assigned to %s", job.report.GetReportID())
+	}
+	job.markStarted(err)
+	return err
+}
+
+// Action sends a report of the next action of the job
+func (job *Job) Action(ctx context.Context, actionName string) error {
+	if _, err := job.WaitJobID(ctx); err != nil {
+		return err
+	}
+	job.report.SetActionName(actionName)
+	return job.send(ctx)
+}
+
+// StartChild creates a child job of this job and starts it. The child inherits the customerGUID, reporter and the
+// delivery configuration of this job
+func (job *Job) StartChild(ctx context.Context, actionName, target string) (*Job, error) {
+	childReport := job.report.newChildReport()
+	childReport.ActionName = actionName
+	childReport.Target = target
+	child := newJob(childReport, job)
+
+	job.mu.Lock()
+	job.children = append(job.children, child)
+	job.running.Add(1)
+	job.mu.Unlock()
+
+	if err := child.Start(ctx); err != nil {
+		child.markFinished(JobFailed)
+		return child, err
+	}
+	return child, nil
+}
+
+/*
+Finish sends the final status of the job
+@Input:
+status - the final status, eg. JobSuccess for an action or JobDone for a reporter
+waitForChildren - wait for the child jobs to finish first, if a child failed (or warned) the final status is raised to
+failure (warning). If ctx is done before the children finished, the job finishes as failed without sending its status
+*/
+func (job *Job) Finish(ctx context.Context, status StatusType, waitForChildren bool) error {
+	if waitForChildren {
+		if err := job.waitChildren(ctx); err != nil {
+			job.markFinished(JobFailed)
+			return err
+		}
+		childStatuses := []StatusType{}
+		for _, child := range job.Children() {
+			if childStatus, ok := child.FinalStatus(); ok {
+				childStatuses = append(childStatuses, childStatus)
+			}
+		}
+		switch AggregateStatus(childStatuses...) {
+		case JobFailed:
+			status = JobFailed
+		case JobWarning:
+			if status != JobFailed {
+				status = JobWarning
+			}
+		}
+	}
+	if _, err := job.WaitJobID(ctx); err != nil {
+		job.markFinished(JobFailed)
+		return err
+	}
+	job.report.SetStatus(status)
+	err := job.send(ctx)
+	job.markFinished(status)
+	return err
+}
+
+// FinalStatus returns the status the job finished with, false if it did not finish yet
+func (job *Job) FinalStatus() (StatusType, bool) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	return job.finalStatus, job.finished
+}
+
+/*
+AggregateStatus the status of a group of jobs: failure if any of them failed, warning if any of them warned, success
+otherwise. An empty group has no status
+*/
+func AggregateStatus(statuses ...StatusType) StatusType {
+	if len(statuses) == 0 {
+		return ""
+	}
+	aggregated := JobSuccess
+	for _, status := range statuses {
+		switch status {
+		case JobFailed:
+			return JobFailed
+		case JobWarning:
+			aggregated = JobWarning
+		}
+	}
+	return aggregated
+}
+
+// SortTimeline sorts the reports of a job by ActionIDN, then by Timestamp, keeping the order of equal reports
+func SortTimeline(reports []*BaseReport) {
+	sort.SliceStable(reports, func(i, j int) bool {
+		if reports[i].ActionIDN != reports[j].ActionIDN {
+			return reports[i].ActionIDN < reports[j].ActionIDN
+		}
+		return reports[i].Timestamp.Before(reports[j].Timestamp)
+	})
+}
+
+func (job *Job) waitChildren(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		job.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("child jobs did not finish: %w", ctx.Err())
+	case <-done:
+		return nil
+	}
+}
+
+func (job *Job) markStarted(err error) {
+	job.once.Do(func() {
+		job.startErr = err
+		close(job.started)
+	})
+}
+
+func (job *Job) markFinished(status StatusType) {
+	job.mu.Lock()
+	if job.finished {
+		job.mu.Unlock()
+		return
+	}
+	job.finished = true
+	job.finalStatus = status
+	job.mu.Unlock()
+	if job.parent != nil {
+		job.parent.running.Done()
+	}
+}
+
+// send sends the report and moves to the next actionID, the same way SendAsRoutine does
+func (job *Job) send(ctx context.Context) error {
+	report := job.report
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	status, body, err := report.SendContext(ctx)
+	if err != nil {
+		return err
+	}
+	if status < 200 || status >= 300 {
+		return fmt.Errorf("failed to send report. Status: %d Body:%s", status, body)
+	}
+	report.NextActionID()
+	return nil
+}
+
+// newChildReport returns a new report sharing the customerGUID, reporter and delivery configuration of the report
+func (report *BaseReport) newChildReport() *BaseReport {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	return report.unprotectedNewChildReport()
+}
+
+func (report *BaseReport) unprotectedNewChildReport() *BaseReport {
+	return &BaseReport{
+		CustomerGUID:     report.CustomerGUID,
+		Reporter:         report.Reporter,
+		Status:           JobStarted,
+		ActionID:         "1",
+		ActionIDN:        1,
+		Labels:           copyLabels(report.Labels),
+		eventReceiverUrl: report.eventReceiverUrl,
+		endpoint:         report.endpoint,
+		httpClient:       report.httpClient,
+		transport:        report.transport,
+		retryPolicy:      report.retryPolicy,
+		outbox:           report.outbox,
+		batchingSender:   report.batchingSender,
+		strictStatus:     report.strictStatus,
+		traceContext:     report.traceContext,
+		logger:           report.logger,
+		redactor:         report.redactor,
+		authenticator:    report.authenticator,
+	}
+}
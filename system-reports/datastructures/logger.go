@@ -0,0 +1,88 @@
+package datastructures
+
+import (
+	"sync"
+)
+
+// structured log fields
+const (
+	LogFieldReportID   = "reportID"
+	LogFieldJobID      = "jobID"
+	LogFieldAttempt    = "attempt"
+	LogFieldURL        = "url"
+	LogFieldStatusCode = "statusCode"
+)
+
+// Field a structured log field
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F returns a log field
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+/*
+Logger the logger of the system reports, see the logger package for the log/slog, logr and glog adapters.
+It is set globally with SetLogger, and per reporter with BaseReport.SetLogger. The default logger discards everything
+*/
+type Logger interface {
+	Debug(msg string, fields ...Field)
+	Info(msg string, fields ...Field)
+	Warn(msg string, fields ...Field)
+	Error(msg string, err error, fields ...Field)
+}
+
+// NopLogger discards everything, it is the default logger
+type NopLogger struct{}
+
+func (NopLogger) Debug(msg string, fields ...Field)            {}
+func (NopLogger) Info(msg string, fields ...Field)             {}
+func (NopLogger) Warn(msg string, fields ...Field)             {}
+func (NopLogger) Error(msg string, err error, fields ...Field) {}
+
+var (
+	globalLogger   Logger = NopLogger{}
+	globalLoggerMu sync.RWMutex
+)
+
+// SetLogger sets the logger of the reporters without a logger of their own, nil restores the default (NopLogger)
+func SetLogger(logger Logger) {
+	globalLoggerMu.Lock()
+	defer globalLoggerMu.Unlock()
+	if logger == nil {
+		logger = NopLogger{}
+	}
+	globalLogger = logger
+}
+
+// GetLogger returns the global logger
+func GetLogger() Logger {
+	globalLoggerMu.RLock()
+	defer globalLoggerMu.RUnlock()
+	return globalLogger
+}
+
+// Logger returns the logger of the report (the global logger if the report has none), redacting with the redactor of
+// the report
+func (report *BaseReport) Logger() Logger {
+	logger := report.logger
+	if logger == nil {
+		logger = GetLogger()
+	}
+	return NewRedactingLogger(logger, report.getRedactor())
+}
+
+// SetLogger sets the logger of the report, nil to use the global logger
+func (report *BaseReport) SetLogger(logger Logger) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	report.logger = logger
+}
+
+// logFields the fields identifying the report in every log line
+func (report *BaseReport) logFields(fields ...Field) []Field {
+	return append([]Field{F(LogFieldReportID, report.GetReportID()), F(LogFieldJobID, report.JobID)}, fields...)
+}
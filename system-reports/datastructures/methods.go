@@ -1,17 +1,14 @@
 package datastructures
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/armosec/utils-go/httputils"
-	"github.com/golang/glog"
 )
 
 var MAX_RETRIES int = 3
@@ -21,27 +18,29 @@ func (report *BaseReport) InitMutex() {
 	report.mutex = sync.Mutex{}
 }
 
+// NextActionID moves the report to the next action. The ActionID of a forked branch (eg. "3.1") is kept, also when
+// its nested branches are joined (see Join), the next number would be the ActionID of its sibling branch
 func (report *BaseReport) NextActionID() {
+	if isBranchActionID(report.ActionID) {
+		return
+	}
 	report.ActionIDN++
 	report.ActionID = report.GetNextActionId()
 }
 func (report *BaseReport) SimpleReportAnnotations(setParent bool, setCurrent bool) (string, string) {
 
-	nextActionID := report.GetNextActionId()
-
-	jobs := JobsAnnotations{LastActionID: nextActionID}
-	if setParent {
-		jobs.ParentJobID = report.JobID
-	}
-	if setCurrent {
-		jobs.CurrJobID = report.JobID
-	}
+	jobs := report.NewJobsAnnotations(setParent, setCurrent)
 	jsonAsString, _ := json.Marshal(jobs)
-	return string(jsonAsString), nextActionID
+	return string(jsonAsString), jobs.LastActionID
 	//ok
 }
 
+// GetNextActionId returns the ActionID the next report is sent with - the current one, since the actionID is moved
+// forward right after every sent report
 func (report *BaseReport) GetNextActionId() string {
+	if isBranchActionID(report.ActionID) {
+		return report.ActionID
+	}
 	return strconv.Itoa(report.ActionIDN)
 }
 
@@ -52,13 +51,19 @@ func (report *BaseReport) AddError(er string) {
 		report.Errors = make([]string, 0)
 	}
 	report.Errors = append(report.Errors, er)
+	report.ErrorDetails = append(report.ErrorDetails, newErrorDetail(report.ActionName, report.ActionID, SeverityError, errors.New(er)))
 }
 
 // The caller must read the errChan, to prevent the goroutine from waiting in memory forever
 func (report *BaseReport) SendAsRoutine(progressNext bool, errChan chan<- error) {
+	report.SendAsRoutineContext(context.Background(), progressNext, errChan)
+}
+
+// SendAsRoutineContext - like SendAsRoutine, but the send is aborted once ctx is done and ctx.Err() is returned on the errChan
+func (report *BaseReport) SendAsRoutineContext(ctx context.Context, progressNext bool, errChan chan<- error) {
 	report.mutex.Lock()
 	wg := &sync.WaitGroup{}
-	report.unprotectedSendAsRoutine(errChan, progressNext, wg)
+	report.unprotectedSendAsRoutine(ctx, errChan, progressNext, wg)
 	go func(report *BaseReport) {
 		wg.Wait()
 		report.mutex.Unlock()
@@ -66,7 +71,7 @@ func (report *BaseReport) SendAsRoutine(progressNext bool, errChan chan<- error)
 }
 
 //internal send as routine without mutex lock
-func (report *BaseReport) unprotectedSendAsRoutine(errChan chan<- error, progressNext bool, wg *sync.WaitGroup) {
+func (report *BaseReport) unprotectedSendAsRoutine(ctx context.Context, errChan chan<- error, progressNext bool, wg *sync.WaitGroup) {
 	wg.Add(1)
 	go func() {
 
@@ -74,15 +79,15 @@ func (report *BaseReport) unprotectedSendAsRoutine(errChan chan<- error, progres
 			wg.Done()
 			recover()
 		}()
-		status, body, err := report.Send()
+		status, body, err := report.SendContext(ctx)
 		if errChan != nil {
 			if err != nil {
-				errorChannelSend(errChan, err)
+				report.errorChannelSend(errChan, err)
 				return
 			}
 			if status < 200 || status >= 300 {
 				err := fmt.Errorf("failed to send report. Status: %d Body:%s", status, body)
-				errorChannelSend(errChan, err)
+				report.errorChannelSend(errChan, err)
 				return
 			}
 		}
@@ -90,12 +95,12 @@ func (report *BaseReport) unprotectedSendAsRoutine(errChan chan<- error, progres
 			report.NextActionID()
 		}
 		if errChan != nil {
-			errorChannelSend(errChan, err)
+			report.errorChannelSend(errChan, err)
 		}
 	}()
 }
 
-func errorChannelSend(errChan chan<- error, err error) {
+func (report *BaseReport) errorChannelSend(errChan chan<- error, err error) {
 	if errChan == nil {
 		return
 	}
@@ -108,7 +113,10 @@ func errorChannelSend(errChan chan<- error, err error) {
 			time.Sleep(time.Millisecond)
 		}
 	}
-	glog.Errorf("Failed to send error to the error channel after 0.5 seconds") //TODO - remove log
+	if recorder := getMetricsRecorder(); recorder != nil {
+		recorder.ObserveErrChanDrop()
+	}
+	report.Logger().Error("failed to send error to the error channel after 0.5 seconds", err, report.logFields()...)
 }
 
 func (report *BaseReport) GetReportID() string {
@@ -117,7 +125,20 @@ func (report *BaseReport) GetReportID() string {
 
 // Send - send http request. returns-> http status code, return message (jobID/OK), http/go error
 func (report *BaseReport) Send() (int, string, error) {
-	url := report.eventReceiverUrl + systemReportEndpoint.GetOrDefault()
+	return report.SendContext(context.Background())
+}
+
+// SendContext - like Send, but the retries, the delay between them and the http request itself stop once ctx is done.
+// In that case ctx.Err() is returned as the error
+func (report *BaseReport) SendContext(ctx context.Context) (status int, body string, err error) {
+	attempts, lastStatusCode, start := 0, 0, time.Now()
+	if recorder := getMetricsRecorder(); recorder != nil {
+		defer func() {
+			recorder.ObserveSend(report.Reporter, report.Status, lastStatusCode, attempts, time.Since(start), err)
+		}()
+	}
+	transport := report.getTransport()
+	url := transportName(transport)
 	report.Timestamp = time.Now()
 	if report.ActionID == "" {
 		report.ActionID = "1"
@@ -128,49 +149,175 @@ func (report *BaseReport) Send() (int, string, error) {
 	if err != nil {
 		return 500, "Couldn't marshall report object", err
 	}
-	var resp *http.Response
-	var bodyAsStr string
-	for i := 0; i < MAX_RETRIES; i++ {
-		resp, err = httputils.HttpPost(report.httpClient, url, map[string]string{"Content-Type": "application/json"}, reqBody)
-		bodyAsStr = "body could not be fetched"
-		retry := err != nil
-		if resp != nil {
-			if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-				retry = true
-			}
-			if resp.Body != nil {
-				body, err := ioutil.ReadAll(resp.Body)
-				if err == nil {
-					bodyAsStr = string(body)
+	// the report is redacted only by the redactor of the reporter, the global one redacts the errors and logs
+	if report.redactor != nil {
+		if reqBody, err = report.redactor.RedactReport(reqBody); err != nil {
+			return 500, "Couldn't redact report object", err
+		}
+	}
+	redactor := report.getRedactor()
+	// the trace context of the report, else the one of ctx, is sent with the report
+	if report.traceContext.IsValid() {
+		ctx = ContextWithTraceContext(ctx, report.traceContext)
+	}
+	if report.batchingSender != nil {
+		status, body, err := report.batchingSender.send(ctx, reqBody, report.authenticator)
+		// if bulk sending is unavailable the report is sent on its own
+		if !errors.Is(err, errBatchingUnavailable) {
+			attempts, lastStatusCode = 1, status
+			if err != nil {
+				if ctx.Err() != nil {
+					report.observeDrop(DropReasonCanceled)
+				} else {
+					report.putInOutbox(ctx, transport, reqBody, status)
 				}
-				resp.Body.Close()
+				return status, body, err
 			}
+			if len(report.JobID) == 0 && body != "ok" {
+				report.JobID = body
+			}
+			return status, body, nil
+		}
+	}
+	retryPolicy := report.retryPolicy
+	if retryPolicy == nil {
+		retryPolicy = defaultRetryPolicy()
+	}
+	var result Result
+	for i := 0; ; i++ {
+		if ctx.Err() != nil {
+			report.observeDrop(DropReasonCanceled)
+			return 500, fmt.Sprintf("%s - report was not sent: %v", report.GetReportID(), ctx.Err()), ctx.Err()
 		}
-		if !retry {
+		attempts++
+		result, err = transport.Deliver(ctx, reqBody)
+		lastStatusCode = result.StatusCode
+		if err == nil && result.StatusCode >= 200 && result.StatusCode < 300 {
 			break
 		}
-		//else err != nil
-		e := fmt.Errorf("attempt #%d %s - Failed posting report. Url: '%s', reason: '%s' report: '%s' response: '%s'", i, report.GetReportID(), url, err.Error(), string(reqBody), bodyAsStr)
+		//else err != nil or a non 2xx status
+		bodyAsStr := result.Body
+		if err != nil {
+			bodyAsStr = "body could not be fetched"
+		} else {
+			err = fmt.Errorf("unexpected status code %d", result.StatusCode)
+		}
+		e := fmt.Errorf("attempt #%d %s - Failed posting report. Url: '%s', reason: '%s' report: '%s' response: '%s'", i, report.GetReportID(),
+			redactor.RedactString(url), redactor.RedactString(err.Error()), redactor.RedactBody(string(reqBody)), redactor.RedactBody(bodyAsStr))
+		report.Logger().Warn("failed posting report", report.logFields(F(LogFieldAttempt, i+1), F(LogFieldURL, url), F(LogFieldStatusCode, result.StatusCode), F("error", err.Error()))...)
 
-		if i == MAX_RETRIES-1 {
+		if ctx.Err() != nil {
+			report.observeDrop(DropReasonCanceled)
+			return 500, e.Error(), ctx.Err()
+		}
+		delay, ok := retryPolicy.NextRetry(i+1, result.StatusCode, result.RetryAfter, err)
+		if !ok {
+			report.putInOutbox(ctx, transport, reqBody, result.StatusCode)
 			return 500, e.Error(), err
 		}
-		//wait 5 secs between retries
-		time.Sleep(RETRY_DELAY)
+		if recorder := getMetricsRecorder(); recorder != nil {
+			recorder.ObserveRetry(report.Reporter, result.StatusCode)
+		}
+		if !sleepContext(ctx, delay) {
+			report.observeDrop(DropReasonCanceled)
+			return 500, e.Error(), ctx.Err()
+		}
 	}
 	//first successful report gets it's jobID/proccessID
-	if len(report.JobID) == 0 && bodyAsStr != "ok" && resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		report.JobID = bodyAsStr
+	if len(report.JobID) == 0 && result.Body != "ok" {
+		report.JobID = result.Body
+	}
+	return result.StatusCode, result.Body, nil
+
+}
+
+// getTransport returns the transport of the report, an HTTPTransport to the event receiver if none was set
+func (report *BaseReport) getTransport() Transport {
+	if report.transport != nil {
+		return report.transport
 	}
-	return resp.StatusCode, bodyAsStr, nil
+	return &HTTPTransport{EventReceiverUrl: report.eventReceiverUrl, Endpoint: report.endpoint, HttpClient: report.httpClient, Authenticator: report.authenticator}
+}
+
+// transportName describes where the transport delivers to, for errors and the outbox
+func transportName(transport Transport) string {
+	if stringer, ok := transport.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return fmt.Sprintf("%T", transport)
+}
+
+// putInOutbox keeps an undelivered report in the outbox (if set), with the trace context of ctx. Reports rejected with a
+// permanent status are not kept, replaying them would fail the same way, nor are reports without a jobID - the jobID the
+// receiver assigns to a replayed first report would never reach the reporter - nor reports of a transport other than
+// HTTPTransport, the outbox replays them with an http post
+func (report *BaseReport) putInOutbox(ctx context.Context, transport Transport, reqBody []byte, statusCode int) {
+	httpTransport, isHTTP := transport.(*HTTPTransport)
+	switch {
+	case !IsRetryableStatus(statusCode):
+		report.observeDrop(DropReasonRejected)
+		return
+	case report.outbox == nil:
+		report.observeDrop(DropReasonNoOutbox)
+		return
+	case report.JobID == "":
+		report.observeDrop(DropReasonNoJobID)
+		return
+	case !isHTTP:
+		report.observeDrop(DropReasonNotHTTP)
+		return
+	}
+	entry := OutboxEntry{URL: httpTransport.URL(), ReportID: report.GetReportID(), Report: reqBody, Timestamp: time.Now()}
+	if tc, ok := TraceContextFromContext(ctx); ok {
+		entry.TraceParent, entry.TraceState = tc.TraceParent(), tc.TraceState
+	}
+	if err := report.outbox.Put(entry); err != nil {
+		report.observeDrop(DropReasonOutboxError)
+		report.Logger().Error("failed to put report in the outbox", err, report.logFields(F(LogFieldURL, entry.URL))...)
+	}
+}
+
+func (report *BaseReport) observeDrop(reason string) {
+	if recorder := getMetricsRecorder(); recorder != nil {
+		recorder.ObserveDrop(report.Reporter, reason)
+	}
+}
 
+// sleepContext waits for the given duration, returns false if ctx is done before it elapsed
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
 }
 
 // ======================================== SEND WRAPPER =======================================
 
 // SendError - wrap AddError
 func (report *BaseReport) SendError(err error, sendReport bool, initErrors bool, errChan chan<- error) {
+	report.SendErrorContext(context.Background(), err, sendReport, initErrors, errChan)
+}
+
+// SendErrorContext - like SendError, the report is sent using SendContext
+//
+// With strict status transitions (see SetStrictStatus) a report that cannot fail is left unchanged and not sent, the
+// error is returned on the errChan
+func (report *BaseReport) SendErrorContext(ctx context.Context, err error, sendReport bool, initErrors bool, errChan chan<- error) {
 	report.mutex.Lock() // +
+	if statusErr := report.doSetStatus(JobFailed); statusErr != nil { // TODO - Add flag?
+		report.mutex.Unlock()
+		if errChan != nil {
+			go func() { report.errorChannelSend(errChan, statusErr) }()
+		}
+		return
+	}
 
 	if report.Errors == nil {
 		report.Errors = make([]string, 0)
@@ -178,107 +325,149 @@ func (report *BaseReport) SendError(err error, sendReport bool, initErrors bool,
 	if err != nil {
 		e := fmt.Sprintf("Action: %s, Error: %s", report.ActionName, err.Error())
 		report.Errors = append(report.Errors, e)
+		report.ErrorDetails = append(report.ErrorDetails, newErrorDetail(report.ActionName, report.ActionID, SeverityError, err))
 	}
-	report.Status = JobFailed // TODO - Add flag?
 
 	if sendReport {
 		wg := &sync.WaitGroup{}
-		report.unprotectedSendAsRoutine(errChan, true, wg)
+		report.unprotectedSendAsRoutine(ctx, errChan, true, wg)
 		go func(report *BaseReport) {
 			wg.Wait()
 			if initErrors {
 				report.Errors = make([]string, 0)
+				report.ErrorDetails = nil
 			}
 			report.mutex.Unlock() // -
 		}(report)
 	} else {
-		go func() { errorChannelSend(errChan, nil) }()
+		go func() { report.errorChannelSend(errChan, nil) }()
 		if initErrors {
 			report.Errors = make([]string, 0)
+			report.ErrorDetails = nil
 		}
 		report.mutex.Unlock() // -
 	}
 }
 
 func (report *BaseReport) SendWarning(warnMsg string, sendReport bool, initWarnings bool, errChan chan<- error) {
+	report.SendWarningContext(context.Background(), warnMsg, sendReport, initWarnings, errChan)
+}
+
+// SendWarningContext - like SendWarning, the report is sent using SendContext
+//
+// With strict status transitions (see SetStrictStatus) a report that cannot warn is left unchanged and not sent, the
+// error is returned on the errChan
+func (report *BaseReport) SendWarningContext(ctx context.Context, warnMsg string, sendReport bool, initWarnings bool, errChan chan<- error) {
 	report.mutex.Lock() // +
+	if err := report.doSetStatus(JobWarning); err != nil {
+		report.mutex.Unlock()
+		if errChan != nil {
+			go func() { report.errorChannelSend(errChan, err) }()
+		}
+		return
+	}
 	if report.Errors == nil {
 		report.Errors = make([]string, 0)
 	}
 	if len(warnMsg) != 0 {
 		e := fmt.Sprintf("Action: %s, Error: %s", report.ActionName, warnMsg)
 		report.Errors = append(report.Errors, e)
+		report.ErrorDetails = append(report.ErrorDetails, newErrorDetail(report.ActionName, report.ActionID, SeverityWarning, errors.New(warnMsg)))
 	}
-	report.Status = JobWarning
 
 	if sendReport {
 		wg := &sync.WaitGroup{}
-		report.unprotectedSendAsRoutine(errChan, true, wg)
+		report.unprotectedSendAsRoutine(ctx, errChan, true, wg)
 		go func(report *BaseReport) {
 			wg.Wait()
 			if initWarnings {
 				report.Errors = make([]string, 0)
+				report.ErrorDetails = nil
 			}
 			report.mutex.Unlock() // -
 		}(report)
 	} else {
-		go func() { errorChannelSend(errChan, nil) }()
+		go func() { report.errorChannelSend(errChan, nil) }()
 		if initWarnings {
 			report.Errors = make([]string, 0)
+			report.ErrorDetails = nil
 		}
 		report.mutex.Unlock() // -
 	}
 }
 
 func (report *BaseReport) SendAction(actionName string, sendReport bool, errChan chan<- error) {
+	report.SendActionContext(context.Background(), actionName, sendReport, errChan)
+}
+
+// SendActionContext - like SendAction, the report is sent using SendContext
+func (report *BaseReport) SendActionContext(ctx context.Context, actionName string, sendReport bool, errChan chan<- error) {
 	report.mutex.Lock()
 	report.doSetActionName(actionName)
 	if sendReport {
 		wg := &sync.WaitGroup{}
-		report.unprotectedSendAsRoutine(errChan, true, wg)
+		report.unprotectedSendAsRoutine(ctx, errChan, true, wg)
 		go func(report *BaseReport) {
 			wg.Wait()
 			report.mutex.Unlock() // -
 		}(report)
 	} else {
 		if errChan != nil {
-			go func() { errorChannelSend(errChan, nil) }()
+			go func() { report.errorChannelSend(errChan, nil) }()
 		}
 		report.mutex.Unlock() // -
 	}
 }
 
-func (report *BaseReport) SendStatus(status string, sendReport bool, errChan chan<- error) {
+func (report *BaseReport) SendStatus(status StatusType, sendReport bool, errChan chan<- error) {
+	report.SendStatusContext(context.Background(), status, sendReport, errChan)
+}
+
+// SendStatusContext - like SendStatus, the report is sent using SendContext
+//
+// With strict status transitions (see SetStrictStatus) an invalid status is not set nor sent, the error is returned on the errChan
+func (report *BaseReport) SendStatusContext(ctx context.Context, status StatusType, sendReport bool, errChan chan<- error) {
 	report.mutex.Lock()
-	report.doSetStatus(status)
+	if err := report.doSetStatus(status); err != nil {
+		report.mutex.Unlock()
+		if errChan != nil {
+			go func() { report.errorChannelSend(errChan, err) }()
+		}
+		return
+	}
 	if sendReport {
 		wg := &sync.WaitGroup{}
-		report.unprotectedSendAsRoutine(errChan, true, wg)
+		report.unprotectedSendAsRoutine(ctx, errChan, true, wg)
 		go func(report *BaseReport) {
 			wg.Wait()
 			report.mutex.Unlock() // -
 		}(report)
 	} else {
 		if errChan != nil {
-			go func() { errorChannelSend(errChan, nil) }()
+			go func() { report.errorChannelSend(errChan, nil) }()
 		}
 		report.mutex.Unlock() // -
 	}
 }
 
 func (report *BaseReport) SendDetails(details string, sendReport bool, errChan chan<- error) {
+	report.SendDetailsContext(context.Background(), details, sendReport, errChan)
+}
+
+// SendDetailsContext - like SendDetails, the report is sent using SendContext
+func (report *BaseReport) SendDetailsContext(ctx context.Context, details string, sendReport bool, errChan chan<- error) {
 	report.mutex.Lock()
 	report.doSetDetails(details)
 	if sendReport {
 		wg := &sync.WaitGroup{}
-		report.unprotectedSendAsRoutine(errChan, true, wg)
+		report.unprotectedSendAsRoutine(ctx, errChan, true, wg)
 		go func(report *BaseReport) {
 			wg.Wait()
 			report.mutex.Unlock() // -
 		}(report)
 	} else {
 		if errChan != nil {
-			go func() { errorChannelSend(errChan, nil) }()
+			go func() { report.errorChannelSend(errChan, nil) }()
 		}
 		report.mutex.Unlock() // -
 	}
@@ -295,13 +484,45 @@ func (report *BaseReport) doSetReporter(reporter string) {
 	report.Reporter = strings.ToTitle(reporter)
 }
 
-func (report *BaseReport) SetStatus(status string) {
+// SetStatus sets the status. An invalid transition is logged, with strict status transitions (see SetStrictStatus) the
+// status is also left unchanged - use SetStatusStrict to get the error
+func (report *BaseReport) SetStatus(status StatusType) {
 	report.mutex.Lock()
 	defer report.mutex.Unlock()
 	report.doSetStatus(status)
 }
-func (report *BaseReport) doSetStatus(status string) {
+
+// SetStatusStrict sets the status if the transition to it is valid, else the status is left unchanged and the error
+// is returned, whether strict status transitions are set or not
+func (report *BaseReport) SetStatusStrict(status StatusType) error {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	return report.setStatus(status, true)
+}
+
+// doSetStatus validates the transition to the status. An invalid transition is logged, and with strict status
+// transitions it is rejected - the status is left unchanged and the error is returned
+func (report *BaseReport) doSetStatus(status StatusType) error {
+	return report.setStatus(status, report.strictStatus)
+}
+
+func (report *BaseReport) setStatus(status StatusType, strict bool) error {
+	if err := ValidateTransition(report.Status, status); err != nil {
+		if strict {
+			report.Logger().Warn(fmt.Sprintf("rejected status: %v", err), report.logFields()...)
+			return err
+		}
+		report.Logger().Warn(err.Error(), report.logFields()...)
+	}
 	report.Status = status
+	return nil
+}
+
+// SetStrictStatus rejects invalid status transitions instead of only logging them
+func (report *BaseReport) SetStrictStatus(strict bool) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	report.strictStatus = strict
 }
 
 func (report *BaseReport) SetActionName(actionName string) {
@@ -377,6 +598,67 @@ func (report *BaseReport) doSetActionIDN(actionIDN int) {
 	report.ActionID = strconv.Itoa(report.ActionIDN)
 }
 
+// SetRetryPolicy sets the policy used by Send to retry failed deliveries, nil restores the default (MAX_RETRIES, RETRY_DELAY)
+func (report *BaseReport) SetRetryPolicy(retryPolicy RetryPolicy) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	report.retryPolicy = retryPolicy
+}
+
+// SetOutbox sets the outbox keeping the report snapshots that could not be delivered, nil disables it.
+// The first report of a job (sent without a jobID) is not kept, its jobID is assigned by the receiver and would be lost
+// on replay. Set the jobID (eg. NewJobID) before the first send for its reports to be kept as well.
+// Only reports sent by an HTTPTransport (the default transport) are kept, the outbox replays them with an http post
+func (report *BaseReport) SetOutbox(outbox Outbox) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	report.outbox = outbox
+}
+
+// SetBatchingSender sends the report in bulk with the reports of other BaseReports, nil sends it on its own.
+// Until the batch is sent the report stays locked, the next Send* call on it waits for the result
+func (report *BaseReport) SetBatchingSender(batchingSender *BatchingSender) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	report.batchingSender = batchingSender
+}
+
+// SetEndpoint sets the path the report is posted to, an empty value falls back to the default endpoint (see SetDefaultEndpoint).
+// A path not starting with "/" is logged and leaves the endpoint unchanged
+func (report *BaseReport) SetEndpoint(endpoint string) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	if err := validateEndpoint(endpoint); err != nil {
+		report.Logger().Error("endpoint was not set", err, report.logFields()...)
+		return
+	}
+	report.endpoint = endpoint
+}
+
+// SetTraceContext sets the W3C trace context the report is sent with, see TraceContext
+func (report *BaseReport) SetTraceContext(traceContext TraceContext) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	report.traceContext = traceContext
+}
+
+// SetTransport sets the transport the report is delivered with, nil restores the default HTTPTransport to the event receiver
+func (report *BaseReport) SetTransport(transport Transport) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	report.transport = transport
+}
+
+// SetLabel sets a label of the report
+func (report *BaseReport) SetLabel(key, value string) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	if report.Labels == nil {
+		report.Labels = map[string]string{}
+	}
+	report.Labels[key] = value
+}
+
 func (report *BaseReport) SetTimestamp(timestamp time.Time) {
 	report.mutex.Lock()
 	defer report.mutex.Unlock()
@@ -391,7 +673,7 @@ func (report *BaseReport) GetActionName() string {
 	return report.ActionName
 }
 
-func (report *BaseReport) GetStatus() string {
+func (report *BaseReport) GetStatus() StatusType {
 	return report.Status
 }
 
@@ -399,6 +681,10 @@ func (report *BaseReport) GetErrorList() []string {
 	return report.Errors
 }
 
+func (report *BaseReport) GetErrorDetails() []ErrorDetail {
+	return report.ErrorDetails
+}
+
 func (report *BaseReport) GetTarget() string {
 	return report.Target
 }
@@ -434,3 +720,20 @@ func (report *BaseReport) GetTimestamp() time.Time {
 func (report *BaseReport) GetDetails() string {
 	return report.Details
 }
+
+// GetEndpoint returns the path the report is posted to
+func (report *BaseReport) GetEndpoint() string {
+	if report.endpoint != "" {
+		return report.endpoint
+	}
+	return GetDefaultEndpoint()
+}
+
+// GetLabels returns a copy of the labels of the report
+func (report *BaseReport) GetLabels() map[string]string {
+	return copyLabels(report.Labels)
+}
+
+func (report *BaseReport) GetTraceContext() TraceContext {
+	return report.traceContext
+}
@@ -76,7 +76,7 @@ func (reportMock *BaseReportMock) SendAction(actionName string, sendReport bool)
 	reportMock.SetActionName(actionName)
 }
 
-func (reportMock *BaseReportMock) SendStatus(status string, sendReport bool) {
+func (reportMock *BaseReportMock) SendStatus(status StatusType, sendReport bool) {
 	reportMock.SetStatus(status)
 }
 
@@ -85,7 +85,7 @@ func (reportMock *BaseReportMock) SetReporter(reporter string) {
 	reportMock.Reporter = reporter
 }
 
-func (reportMock *BaseReportMock) SetStatus(status string) {
+func (reportMock *BaseReportMock) SetStatus(status StatusType) {
 	reportMock.Status = status
 }
 
@@ -129,7 +129,7 @@ func (reportMock *BaseReportMock) GetActionName() string {
 	return reportMock.ActionName
 }
 
-func (reportMock *BaseReportMock) GetStatus() string {
+func (reportMock *BaseReportMock) GetStatus() StatusType {
 	return reportMock.Status
 }
 
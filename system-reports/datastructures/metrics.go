@@ -0,0 +1,50 @@
+package datastructures
+
+import (
+	"sync"
+	"time"
+)
+
+// reasons a report was dropped, see MetricsRecorder.ObserveDrop
+const (
+	DropReasonRejected    = "rejected"     // the receiver rejected the report with a permanent status
+	DropReasonNoOutbox    = "no_outbox"    // the report could not be delivered and there is no outbox to keep it
+	DropReasonOutboxError = "outbox_error" // the report could not be delivered nor kept in the outbox
+	DropReasonNoJobID     = "no_job_id"    // the first report of a job is not kept in the outbox, see BaseReport.SetOutbox
+	DropReasonCanceled    = "canceled"     // the context of the send was done before the report was delivered
+	DropReasonNotHTTP     = "not_http"     // the report was not sent by an HTTPTransport, the outbox replays reports over http only
+)
+
+/*
+MetricsRecorder observes the delivery of reports, eg. the Prometheus metrics of the metrics package.
+The recorder is called on the sending goroutine, so it should not block
+*/
+type MetricsRecorder interface {
+	// ObserveSend is called once per sent report with the status of the report, the http status code of the last
+	// attempt (0 if there was no response), the number of attempts, how long the send took and its error (nil if delivered)
+	ObserveSend(reporter string, status StatusType, statusCode int, attempts int, duration time.Duration, err error)
+	// ObserveRetry is called before every retry of a report
+	ObserveRetry(reporter string, statusCode int)
+	// ObserveDrop is called when a report that was not delivered is not kept either, see the DropReason constants
+	ObserveDrop(reporter string, reason string)
+	// ObserveErrChanDrop is called when an error could not be sent to the error channel, since nobody was reading it
+	ObserveErrChanDrop()
+}
+
+var (
+	metricsRecorder   MetricsRecorder
+	metricsRecorderMu sync.RWMutex
+)
+
+// SetMetricsRecorder sets the recorder observing the delivery of all the reports, nil to stop observing
+func SetMetricsRecorder(recorder MetricsRecorder) {
+	metricsRecorderMu.Lock()
+	defer metricsRecorderMu.Unlock()
+	metricsRecorder = recorder
+}
+
+func getMetricsRecorder() MetricsRecorder {
+	metricsRecorderMu.RLock()
+	defer metricsRecorderMu.RUnlock()
+	return metricsRecorder
+}
@@ -0,0 +1,23 @@
+package datastructures
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxEntry a report that could not be delivered, as it was posted to the event receiver
+type OutboxEntry struct {
+	URL         string          `json:"url"`                   // full url the report was posted to
+	ReportID    string          `json:"reportID"`              // GetReportID of the report, for logging
+	Report      json.RawMessage `json:"report"`                // the serialized report
+	Timestamp   time.Time       `json:"timestamp"`             // time the report was put in the outbox
+	TraceParent string          `json:"traceParent,omitempty"` // traceparent header the report was posted with, if any
+	TraceState  string          `json:"traceState,omitempty"`  // tracestate header the report was posted with, if any
+}
+
+// Outbox keeps reports that ran out of retries so they can be replayed once the event receiver is back.
+// Only reports that have a jobID and were posted by an HTTPTransport are put in the outbox, see BaseReport.SetOutbox
+type Outbox interface {
+	// Put persists an undelivered report, an error is returned if the report could not be kept
+	Put(entry OutboxEntry) error
+}
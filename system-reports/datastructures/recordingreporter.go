@@ -0,0 +1,154 @@
+package datastructures
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ IContextReporter = (*RecordingReporter)(nil)
+
+// ReportSnapshot a report as it was sent
+type ReportSnapshot struct {
+	Reporter     string        `json:"reporter"`
+	Target       string        `json:"target"`
+	Status       StatusType    `json:"status"`
+	ActionName   string        `json:"action"`
+	Errors       []string      `json:"errors,omitempty"`
+	ErrorDetails []ErrorDetail `json:"errorDetails,omitempty"`
+	ActionID     string        `json:"actionID"`
+	ActionIDN    int           `json:"numSeq"`
+	JobID        string        `json:"jobID"`
+	ParentAction string        `json:"parentAction,omitempty"`
+	Details      string        `json:"details,omitempty"`
+	Timestamp    time.Time     `json:"timestamp"`
+}
+
+// TestingT the part of *testing.T used by the RecordingReporter assertions
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+/*
+RecordingReporter an IReporter (and IContextReporter) test double. It is a real BaseReport (same locking, actionID and jobID behavior)
+whose reports are recorded in memory instead of being sent, the first report of a job is given a generated jobID.
+
+	reporter := NewRecordingReporter("customer-guid", "my-component")
+	functionUnderTest(reporter)
+	reporter.AssertStatusSequence(t, JobStarted, JobSuccess)
+*/
+type RecordingReporter struct {
+	*BaseReport
+	recorder *recordingTransport
+}
+
+// NewRecordingReporter returns a RecordingReporter initialized like NewBaseReport
+func NewRecordingReporter(customerGUID, reporter string) *RecordingReporter {
+	recorder := &recordingTransport{}
+	report := NewBaseReport(customerGUID, reporter, "", nil)
+	report.transport = recorder
+	return &RecordingReporter{BaseReport: report, recorder: recorder}
+}
+
+// Wait waits for the reports sent as routines to be recorded
+func (r *RecordingReporter) Wait() {
+	r.mutex.Lock()
+	r.mutex.Unlock()
+}
+
+// Reports returns the recorded reports in the order they were sent
+func (r *RecordingReporter) Reports() []ReportSnapshot {
+	r.Wait()
+	return r.recorder.snapshots()
+}
+
+// Statuses returns the status of every recorded report
+func (r *RecordingReporter) Statuses() []StatusType {
+	reports := r.Reports()
+	statuses := make([]StatusType, len(reports))
+	for i := range reports {
+		statuses[i] = reports[i].Status
+	}
+	return statuses
+}
+
+// AssertSent checks that n reports were sent
+func (r *RecordingReporter) AssertSent(t TestingT, n int) bool {
+	if sent := len(r.Reports()); sent != n {
+		t.Errorf("expected %d reports to be sent, %d were sent", n, sent)
+		return false
+	}
+	return true
+}
+
+// AssertStatusSequence checks the statuses of the sent reports, in order
+func (r *RecordingReporter) AssertStatusSequence(t TestingT, statuses ...StatusType) bool {
+	if sent := r.Statuses(); !reflect.DeepEqual(sent, statuses) {
+		t.Errorf("expected status sequence %s, got %s", joinStatuses(statuses), joinStatuses(sent))
+		return false
+	}
+	return true
+}
+
+func joinStatuses(statuses []StatusType) string {
+	s := make([]string, len(statuses))
+	for i := range statuses {
+		s[i] = string(statuses[i])
+	}
+	return strings.Join(s, "→")
+}
+
+// AssertErrorsContain checks that one of the errors of a sent report contains substr
+func (r *RecordingReporter) AssertErrorsContain(t TestingT, substr string) bool {
+	sent := []string{}
+	for _, report := range r.Reports() {
+		for _, e := range report.Errors {
+			if strings.Contains(e, substr) {
+				return true
+			}
+			sent = append(sent, e)
+		}
+	}
+	t.Errorf("expected the sent errors to contain '%s', got %q", substr, sent)
+	return false
+}
+
+// recordingTransport keeps a snapshot of every delivered report
+type recordingTransport struct {
+	mu      sync.Mutex
+	reports []ReportSnapshot
+}
+
+func (t *recordingTransport) Deliver(ctx context.Context, report []byte) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	snapshot := ReportSnapshot{}
+	if err := json.Unmarshal(report, &snapshot); err != nil {
+		return Result{}, fmt.Errorf("failed to decode report: %w", err)
+	}
+	body := "ok"
+	if snapshot.JobID == "" {
+		jobID, err := NewJobID()
+		if err != nil {
+			return Result{}, err
+		}
+		body = jobID
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reports = append(t.reports, snapshot)
+	return Result{StatusCode: 200, Body: body}, nil
+}
+
+func (t *recordingTransport) snapshots() []ReportSnapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	snapshots := make([]ReportSnapshot, len(t.reports))
+	copy(snapshots, t.reports)
+	return snapshots
+}
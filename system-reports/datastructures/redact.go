@@ -0,0 +1,244 @@
+package datastructures
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	defaultRedactionMask = "[REDACTED]"
+	defaultMaxBodySize   = 1024
+)
+
+// RedactionRule replaces the matches of Pattern with Replacement (regexp.ReplaceAllString syntax, eg. "${1}[REDACTED]")
+type RedactionRule struct {
+	Name        string
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// DefaultRedactionRules the known secret patterns: bearer/basic credentials, JWTs, AWS keys, url passwords and
+// password/secret/token/key values (eg. in query strings). A value is matched right after "=" or ":", or quoted after
+// them, so ordinary text like "failed to get token: connection refused" is left as is
+func DefaultRedactionRules() []RedactionRule {
+	return []RedactionRule{
+		{Name: "bearer", Pattern: regexp.MustCompile(`(?i)\b(bearer\s+)[A-Za-z0-9\-._~+/]+=*`), Replacement: "${1}" + defaultRedactionMask},
+		{Name: "basic", Pattern: regexp.MustCompile(`(?i)\b(basic\s+)[A-Za-z0-9+/]{8,}=*`), Replacement: "${1}" + defaultRedactionMask},
+		{Name: "jwt", Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`), Replacement: defaultRedactionMask},
+		{Name: "aws-access-key-id", Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`), Replacement: defaultRedactionMask},
+		{Name: "aws-secret-access-key", Pattern: regexp.MustCompile(`(?i)(aws_?secret_?access_?key["']?\s*[:=]\s*["']?)[A-Za-z0-9/+=]{40}`), Replacement: "${1}" + defaultRedactionMask},
+		{Name: "url-password", Pattern: regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+@`), Replacement: "${1}" + defaultRedactionMask + "@"},
+		{Name: "secret-value", Pattern: regexp.MustCompile(`(?i)((?:password|passwd|secret|token|api[_-]?key|access[_-]?key|signature)(?:["']?\s*[:=]\s*["']|["']?[:=]["']?))[^\s"'&,;]+`), Replacement: "${1}" + defaultRedactionMask},
+	}
+}
+
+// RedactorConfig Redactor configuration
+type RedactorConfig struct {
+	Rules          []RedactionRule // applied after the default rules
+	NoDefaultRules bool            // do not apply DefaultRedactionRules
+	MaskFields     []string        // JSON fields of the report whose values are masked entirely (at any depth), eg. "customerGUID"
+	MaxBodySize    int             // report and response bodies in errors and logs are truncated to this size, default 1024, -1 for no limit
+	Mask           string          // the masked value of MaskFields, default "[REDACTED]"
+}
+
+/*
+Redactor removes secrets and PII from the errors of Send (url, report and response), from the log output and, if set
+on the reporter, from the reports (Errors, Details, ErrorDetails... before they are sent).
+
+It is set globally with SetRedactor (the default redacts DefaultRedactionRules) and per reporter with
+BaseReport.SetRedactor or WithRedactor. The global redactor applies to the errors and logs only, the reports that are
+sent are redacted only by the redactor of their reporter. A nil Redactor redacts nothing
+*/
+type Redactor struct {
+	rules       []RedactionRule
+	maskFields  map[string]bool
+	maxBodySize int
+	mask        string
+}
+
+// NewRedactor returns a Redactor
+func NewRedactor(config RedactorConfig) *Redactor {
+	r := &Redactor{maskFields: map[string]bool{}, maxBodySize: config.MaxBodySize, mask: config.Mask}
+	if !config.NoDefaultRules {
+		r.rules = DefaultRedactionRules()
+	}
+	r.rules = append(r.rules, config.Rules...)
+	for _, field := range config.MaskFields {
+		r.maskFields[field] = true
+	}
+	if r.maxBodySize == 0 {
+		r.maxBodySize = defaultMaxBodySize
+	}
+	if r.mask == "" {
+		r.mask = defaultRedactionMask
+	}
+	return r
+}
+
+// RedactString applies the rules to s
+func (r *Redactor) RedactString(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, rule := range r.rules {
+		if rule.Pattern != nil {
+			s = rule.Pattern.ReplaceAllString(s, rule.Replacement)
+		}
+	}
+	return s
+}
+
+// RedactBody applies the rules to a report or response body and truncates it to MaxBodySize, for errors and logs
+func (r *Redactor) RedactBody(body string) string {
+	if r == nil {
+		return body
+	}
+	return r.Truncate(r.RedactString(body))
+}
+
+// Truncate truncates s to MaxBodySize, noting how many bytes were cut
+func (r *Redactor) Truncate(s string) string {
+	if r == nil || r.maxBodySize < 0 || len(s) <= r.maxBodySize {
+		return s
+	}
+	cut := r.maxBodySize
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s...(%d bytes truncated)", s[:cut], len(s)-cut)
+}
+
+// RedactReport applies the rules to the string values of a serialized report and masks MaskFields. The report is
+// returned as is if nothing was redacted
+func (r *Redactor) RedactReport(report []byte) ([]byte, error) {
+	if r == nil || (len(r.rules) == 0 && len(r.maskFields) == 0) {
+		return report, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(report))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("failed to decode report for redaction: %w", err)
+	}
+	value, changed := r.redactValue(value)
+	if !changed {
+		return report, nil
+	}
+	return json.Marshal(value)
+}
+
+func (r *Redactor) redactValue(value interface{}) (interface{}, bool) {
+	changed := false
+	switch v := value.(type) {
+	case string:
+		redacted := r.RedactString(v)
+		return redacted, redacted != v
+	case map[string]interface{}:
+		for key, field := range v {
+			if r.maskFields[key] {
+				if s, ok := field.(string); !ok || (s != "" && s != r.mask) {
+					v[key] = r.mask
+					changed = true
+				}
+				continue
+			}
+			if redacted, ok := r.redactValue(field); ok {
+				v[key] = redacted
+				changed = true
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			if redacted, ok := r.redactValue(v[i]); ok {
+				v[i] = redacted
+				changed = true
+			}
+		}
+	}
+	return value, changed
+}
+
+var (
+	globalRedactor   = NewRedactor(RedactorConfig{})
+	globalRedactorMu sync.RWMutex
+)
+
+// SetRedactor sets the redactor of the errors and logs of the reporters without a redactor of their own, nil to redact
+// nothing. The reports themselves are not redacted by it, see BaseReport.SetRedactor
+func SetRedactor(redactor *Redactor) {
+	globalRedactorMu.Lock()
+	defer globalRedactorMu.Unlock()
+	globalRedactor = redactor
+}
+
+// GetRedactor returns the global redactor
+func GetRedactor() *Redactor {
+	globalRedactorMu.RLock()
+	defer globalRedactorMu.RUnlock()
+	return globalRedactor
+}
+
+// SetRedactor sets the redactor of the report. It redacts the report before it is sent, as well as its errors and logs.
+// nil sends the report as is, and redacts the errors and logs with the global redactor
+func (report *BaseReport) SetRedactor(redactor *Redactor) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	report.redactor = redactor
+}
+
+func (report *BaseReport) getRedactor() *Redactor {
+	if report.redactor != nil {
+		return report.redactor
+	}
+	return GetRedactor()
+}
+
+// NewRedactingLogger returns a logger redacting the messages, errors and string fields before passing them to the logger
+func NewRedactingLogger(logger Logger, redactor *Redactor) Logger {
+	if _, ok := logger.(NopLogger); ok || redactor == nil {
+		return logger
+	}
+	return &redactingLogger{logger: logger, redactor: redactor}
+}
+
+type redactingLogger struct {
+	logger   Logger
+	redactor *Redactor
+}
+
+func (l *redactingLogger) Debug(msg string, fields ...Field) {
+	l.logger.Debug(l.redactor.RedactString(msg), l.fields(fields)...)
+}
+
+func (l *redactingLogger) Info(msg string, fields ...Field) {
+	l.logger.Info(l.redactor.RedactString(msg), l.fields(fields)...)
+}
+
+func (l *redactingLogger) Warn(msg string, fields ...Field) {
+	l.logger.Warn(l.redactor.RedactString(msg), l.fields(fields)...)
+}
+
+func (l *redactingLogger) Error(msg string, err error, fields ...Field) {
+	if err != nil {
+		err = errors.New(l.redactor.RedactBody(err.Error()))
+	}
+	l.logger.Error(l.redactor.RedactString(msg), err, l.fields(fields)...)
+}
+
+func (l *redactingLogger) fields(fields []Field) []Field {
+	redacted := make([]Field, len(fields))
+	for i, field := range fields {
+		redacted[i] = field
+		if l.redactor.maskFields[field.Key] {
+			redacted[i].Value = l.redactor.mask
+		} else if s, ok := field.Value.(string); ok {
+			redacted[i].Value = l.redactor.RedactBody(s)
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,159 @@
+package datastructures
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MaxRetryAfter the longest Retry-After honored by policies without a MaxDelay, a longer one is cut to it
+var MaxRetryAfter = 5 * time.Minute
+
+// RetryPolicy decides if and when a failed report delivery is retried
+type RetryPolicy interface {
+	/*
+		NextRetry is called after every failed delivery attempt
+		@Input:
+		attempt - number of attempts done so far (starting at 1)
+		statusCode - http status of the response, 0 if no response was received
+		retryAfter - the delay requested by the server using the Retry-After header, 0 if missing
+		err - the error of the attempt
+
+		@returns:
+		 the delay before the next attempt, false if the report should not be retried
+	*/
+	NextRetry(attempt int, statusCode int, retryAfter time.Duration, err error) (time.Duration, bool)
+}
+
+// IsRetryableStatus returns true if a delivery that ended with the given status code is worth retrying.
+// 0 stands for no response (e.g. network error) and is retried, so are 408, 429 and 5xx.
+// Any other 4xx is permanent (bad request, unauthorized, not found...) and fails fast
+func IsRetryableStatus(statusCode int) bool {
+	switch {
+	case statusCode == 0:
+		return true
+	case statusCode == http.StatusRequestTimeout, statusCode == http.StatusTooManyRequests:
+		return true
+	case statusCode >= 400 && statusCode < 500:
+		return false
+	}
+	return true
+}
+
+// ConstantRetryPolicy waits the same delay between attempts
+type ConstantRetryPolicy struct {
+	MaxAttempts int           // total number of attempts, including the first one
+	Delay       time.Duration // delay between attempts
+}
+
+func (p *ConstantRetryPolicy) NextRetry(attempt int, statusCode int, retryAfter time.Duration, err error) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts || !IsRetryableStatus(statusCode) {
+		return 0, false
+	}
+	return withRetryAfter(p.Delay, retryAfter, 0), true
+}
+
+// ExponentialRetryPolicy multiplies the delay after every attempt, the delay is randomized by Jitter
+// so many reporters failing together will not retry at the same moment
+type ExponentialRetryPolicy struct {
+	MaxAttempts  int           // total number of attempts, including the first one
+	InitialDelay time.Duration // delay after the first attempt
+	MaxDelay     time.Duration // upper limit of the delay, including Retry-After. 0 for no limit (Retry-After is cut to MaxRetryAfter)
+	Multiplier   float64       // delay growth factor, defaults to 2
+	Jitter       float64       // fraction [0,1] of the delay that is randomized. eg. 0.5 waits between 50% and 100% of the delay
+}
+
+// NewExponentialRetryPolicy returns an exponential policy with a multiplier of 2 and a jitter of 0.5
+func NewExponentialRetryPolicy(maxAttempts int, initialDelay, maxDelay time.Duration) *ExponentialRetryPolicy {
+	return &ExponentialRetryPolicy{
+		MaxAttempts:  maxAttempts,
+		InitialDelay: initialDelay,
+		MaxDelay:     maxDelay,
+		Multiplier:   2,
+		Jitter:       0.5,
+	}
+}
+
+func (p *ExponentialRetryPolicy) NextRetry(attempt int, statusCode int, retryAfter time.Duration, err error) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts || !IsRetryableStatus(statusCode) {
+		return 0, false
+	}
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	delay := float64(p.InitialDelay) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		delay -= delay * jitter * rand.Float64()
+	}
+	return withRetryAfter(floatDuration(delay), retryAfter, p.MaxDelay), true
+}
+
+// floatDuration converts a delay in nanoseconds to a Duration, a delay too long for a Duration (eg. after many attempts
+// without MaxDelay) is cut to the longest Duration
+func floatDuration(delay float64) time.Duration {
+	switch {
+	case math.IsNaN(delay) || delay <= 0:
+		return 0
+	case delay >= float64(math.MaxInt64):
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(delay)
+}
+
+// NoRetryPolicy never retries
+type NoRetryPolicy struct{}
+
+func (NoRetryPolicy) NextRetry(attempt int, statusCode int, retryAfter time.Duration, err error) (time.Duration, bool) {
+	return 0, false
+}
+
+// defaultRetryPolicy is used by reports that have no policy set, it follows MAX_RETRIES and RETRY_DELAY
+func defaultRetryPolicy() RetryPolicy {
+	return &ConstantRetryPolicy{MaxAttempts: MAX_RETRIES, Delay: RETRY_DELAY}
+}
+
+// parseRetryAfter parses the Retry-After header, given either in seconds or as an http date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		if int64(seconds) > math.MaxInt64/int64(time.Second) {
+			return time.Duration(math.MaxInt64)
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// withRetryAfter returns the delay, or the Retry-After of the server if it is longer. Retry-After is cut to maxDelay,
+// or to MaxRetryAfter if maxDelay is 0, so a server cannot hold the report for hours
+func withRetryAfter(delay, retryAfter, maxDelay time.Duration) time.Duration {
+	if maxDelay <= 0 {
+		maxDelay = MaxRetryAfter
+	}
+	if retryAfter > maxDelay {
+		retryAfter = maxDelay
+	}
+	return maxDuration(delay, retryAfter)
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
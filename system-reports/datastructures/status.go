@@ -0,0 +1,47 @@
+package datastructures
+
+import (
+	"fmt"
+)
+
+// statusTransitions the statuses a report may move to from each status. A report may always move from the empty status
+var statusTransitions = map[StatusType][]StatusType{
+	JobStarted: {JobStarted, JobSuccess, JobFailed, JobWarning, JobDone},
+	JobSuccess: {JobStarted, JobSuccess, JobFailed, JobWarning, JobDone},
+	JobWarning: {JobStarted, JobSuccess, JobFailed, JobWarning, JobDone},
+	JobFailed:  {JobStarted, JobFailed, JobWarning, JobDone},
+	JobDone:    {},
+}
+
+// IsValid returns true for the known statuses
+func (status StatusType) IsValid() bool {
+	_, ok := statusTransitions[status]
+	return ok
+}
+
+// IsTerminal returns true if no status may follow this one
+func (status StatusType) IsTerminal() bool {
+	next, ok := statusTransitions[status]
+	return ok && len(next) == 0
+}
+
+// ValidateTransition returns an error if a report may not move from one status to the other
+func ValidateTransition(from, to StatusType) error {
+	if !to.IsValid() {
+		return fmt.Errorf("unknown status '%s'", to)
+	}
+	if from == "" {
+		return nil
+	}
+	next, ok := statusTransitions[from]
+	if !ok {
+		// moving away from an unknown status is allowed, the unknown status was already reported
+		return nil
+	}
+	for i := range next {
+		if next[i] == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid status transition '%s' → '%s'", from, to)
+}
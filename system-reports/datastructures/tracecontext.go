@@ -0,0 +1,142 @@
+package datastructures
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// TraceParentHeader the W3C trace context header, "00-<trace-id>-<parent-id>-<trace-flags>"
+	TraceParentHeader = "traceparent"
+	// TraceStateHeader the W3C vendor specific trace state header
+	TraceStateHeader = "tracestate"
+
+	traceFlagSampled = 0x01
+)
+
+/*
+TraceContext a W3C trace context (https://www.w3.org/TR/trace-context/).
+
+A report carrying a trace context is sent with a traceparent header, so reports can be correlated with the OpenTelemetry
+traces of the services processing the same workload
+*/
+type TraceContext struct {
+	TraceID    string // 32 lowercase hex characters
+	SpanID     string // 16 lowercase hex characters, the parent-id of the traceparent
+	TraceFlags byte   // eg. 01 - sampled
+	TraceState string // optional tracestate header value
+}
+
+type traceContextKey struct{}
+
+// ParseTraceParent parses a traceparent header value
+func ParseTraceParent(traceParent string) (TraceContext, error) {
+	tc := TraceContext{}
+	parts := strings.Split(strings.TrimSpace(traceParent), "-")
+	if len(parts) < 4 {
+		return tc, fmt.Errorf("invalid traceparent '%s'", traceParent)
+	}
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || len(version) != 1 || version[0] == 0xff {
+		return tc, fmt.Errorf("invalid traceparent version '%s'", parts[0])
+	}
+	// version 00 has exactly 4 fields, future versions may append fields
+	if version[0] == 0 && len(parts) != 4 {
+		return tc, fmt.Errorf("invalid traceparent '%s'", traceParent)
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || len(flags) != 1 {
+		return tc, fmt.Errorf("invalid traceparent flags '%s'", parts[3])
+	}
+	tc.TraceID = parts[1]
+	tc.SpanID = parts[2]
+	tc.TraceFlags = flags[0]
+	if !tc.IsValid() {
+		return TraceContext{}, fmt.Errorf("invalid traceparent '%s'", traceParent)
+	}
+	return tc, nil
+}
+
+// IsValid returns true if the trace and span IDs are well formed and not all zeros
+func (tc TraceContext) IsValid() bool {
+	return isTraceHexID(tc.TraceID, 32) && isTraceHexID(tc.SpanID, 16)
+}
+
+// IsSampled returns true if the sampled flag is set
+func (tc TraceContext) IsSampled() bool {
+	return tc.TraceFlags&traceFlagSampled != 0
+}
+
+// TraceParent returns the traceparent header value, "" for an invalid trace context
+func (tc TraceContext) TraceParent() string {
+	if !tc.IsValid() {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-%02x", tc.TraceID, tc.SpanID, tc.TraceFlags)
+}
+
+func isTraceHexID(id string, length int) bool {
+	if len(id) != length || strings.Trim(id, "0") == "" {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// InjectTraceHeaders sets the traceparent (and tracestate) headers of a valid trace context
+func InjectTraceHeaders(header http.Header, tc TraceContext) {
+	if !tc.IsValid() {
+		return
+	}
+	header.Set(TraceParentHeader, tc.TraceParent())
+	if tc.TraceState != "" {
+		header.Set(TraceStateHeader, tc.TraceState)
+	}
+}
+
+// ExtractTraceHeaders returns the trace context of the traceparent (and tracestate) headers, false if there is no valid one
+func ExtractTraceHeaders(header http.Header) (TraceContext, bool) {
+	tc, err := ParseTraceParent(header.Get(TraceParentHeader))
+	if err != nil {
+		return TraceContext{}, false
+	}
+	tc.TraceState = header.Get(TraceStateHeader)
+	return tc, true
+}
+
+// ContextWithTraceContext returns a copy of ctx carrying the trace context
+func ContextWithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// TraceContextFromContext returns the trace context ctx carries, false if there is no valid one
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return tc, ok && tc.IsValid()
+}
+
+// InjectTraceAnnotations sets the traceparent of the jobs annotations, so the next component continues the trace
+func InjectTraceAnnotations(jobs *JobsAnnotations, tc TraceContext) {
+	jobs.TraceParent = tc.TraceParent()
+	jobs.TraceState = ""
+	if jobs.TraceParent != "" {
+		jobs.TraceState = tc.TraceState
+	}
+}
+
+// ExtractTraceAnnotations returns the trace context of the jobs annotations, false if there is no valid one
+func ExtractTraceAnnotations(jobs JobsAnnotations) (TraceContext, bool) {
+	tc, err := ParseTraceParent(jobs.TraceParent)
+	if err != nil {
+		return TraceContext{}, false
+	}
+	tc.TraceState = jobs.TraceState
+	return tc, true
+}
@@ -0,0 +1,142 @@
+package datastructures
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/armosec/utils-go/httputils"
+)
+
+// Result the outcome of a single delivery attempt
+type Result struct {
+	StatusCode int           // http status code, transports that are not http return 200 on success
+	Body       string        // jobID for the first report of a job, "ok" from the 2nd report onwards
+	RetryAfter time.Duration // delay requested by the receiver before the next attempt, 0 if none
+}
+
+// Transport delivers a serialized report. BaseReport.Send retries failed deliveries according to its RetryPolicy
+type Transport interface {
+	/*
+		Deliver makes a single delivery attempt
+		@returns:
+		 the result - a non 2xx StatusCode is a failed delivery,
+		 error - if the delivery failed without a result (eg. network error)
+	*/
+	Deliver(ctx context.Context, report []byte) (Result, error)
+}
+
+// HTTPTransport posts the report to the event receiver, this is the default transport of BaseReport
+type HTTPTransport struct {
+	EventReceiverUrl string                // event receiver url
+	Endpoint         string                // path the reports are posted to, default the global endpoint (see SetDefaultEndpoint)
+	HttpClient       httputils.IHttpClient // http client
+	Authenticator    Authenticator         // authenticates the requests, optional
+}
+
+func (t *HTTPTransport) Deliver(ctx context.Context, report []byte) (Result, error) {
+	headers, err := authenticatedHeaders(ctx, t.Authenticator, http.MethodPost, t.URL(), report)
+	if err != nil {
+		return Result{}, err
+	}
+	if tc, ok := TraceContextFromContext(ctx); ok {
+		headers[TraceParentHeader] = tc.TraceParent()
+		if tc.TraceState != "" {
+			headers[TraceStateHeader] = tc.TraceState
+		}
+	}
+	resp, err := httputils.HttpPostWithContext(ctx, t.HttpClient, t.URL(), headers, report)
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{StatusCode: resp.StatusCode, Body: "body could not be fetched"}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		result.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	if resp.Body != nil {
+		body, err := ioutil.ReadAll(resp.Body)
+		if err == nil {
+			result.Body = string(body)
+		}
+		resp.Body.Close()
+	}
+	return result, nil
+}
+
+// URL returns the full url reports are posted to
+func (t *HTTPTransport) URL() string {
+	if t.Endpoint != "" {
+		return t.EventReceiverUrl + t.Endpoint
+	}
+	return t.EventReceiverUrl + GetDefaultEndpoint()
+}
+
+func (t *HTTPTransport) String() string {
+	return t.URL()
+}
+
+// WriterTransport writes every report as a single line to the writer, eg. os.Stdout
+type WriterTransport struct {
+	Writer io.Writer
+	mu     sync.Mutex
+}
+
+// NewWriterTransport returns a transport writing the reports to w
+func NewWriterTransport(w io.Writer) *WriterTransport {
+	return &WriterTransport{Writer: w}
+}
+
+func (t *WriterTransport) Deliver(ctx context.Context, report []byte) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	line := make([]byte, 0, len(report)+1)
+	line = append(line, bytes.TrimSpace(report)...)
+	line = append(line, '\n')
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, err := t.Writer.Write(line); err != nil {
+		return Result{}, fmt.Errorf("failed to write report: %w", err)
+	}
+	return Result{StatusCode: 200, Body: "ok"}, nil
+}
+
+// MemoryTransport keeps the delivered reports in memory
+type MemoryTransport struct {
+	mu      sync.Mutex
+	reports [][]byte
+}
+
+func (t *MemoryTransport) Deliver(ctx context.Context, report []byte) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reports = append(t.reports, append([]byte{}, report...))
+	return Result{StatusCode: 200, Body: "ok"}, nil
+}
+
+// Reports returns the delivered reports, in the order they were delivered
+func (t *MemoryTransport) Reports() [][]byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	reports := make([][]byte, len(t.reports))
+	copy(reports, t.reports)
+	return reports
+}
+
+// failedTransport fails every delivery with the error it was created with (eg. an invalid url)
+type failedTransport struct {
+	err error
+}
+
+func (t *failedTransport) Deliver(ctx context.Context, report []byte) (Result, error) {
+	return Result{StatusCode: 400}, t.err
+}
@@ -0,0 +1,244 @@
+// job trees rebuilt from the system reports of a run, eg. autoattach and the attach of every workload
+package jobtree
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/armosec/logger-go/system-reports/datastructures"
+)
+
+// Node a job of the tree
+type Node struct {
+	JobID       string `json:"jobID"`
+	ParentJobID string `json:"parentJobID,omitempty"` // ParentAction of the reports
+	Reporter    string `json:"reporter"`
+	Target      string `json:"target,omitempty"`
+
+	// Status the status of the last report of the job timeline (sorted by ActionIDN, then by Timestamp)
+	Status datastructures.StatusType `json:"status"`
+	/*
+		AggregatedStatus the status of the job and its descendants: failure if any of their reports failed, warning if
+		any of them warned, started if any of them did not finish and success otherwise
+	*/
+	AggregatedStatus datastructures.StatusType `json:"aggregatedStatus"`
+
+	StartedAt    time.Time     `json:"startedAt"`            // timestamp of the first "started" report, or of the first report if none
+	FinishedAt   *time.Time    `json:"finishedAt,omitempty"` // timestamp of the last report, nil if the job did not finish
+	LastReportAt time.Time     `json:"lastReportAt"`         // latest timestamp of the reports
+	Duration     time.Duration `json:"duration"`             // FinishedAt - StartedAt (in nanoseconds in JSON), 0 if the job did not finish
+	Finished     bool          `json:"finished"`             // the last report is success, failure or done
+	Orphan       bool          `json:"orphan,omitempty"`     // the parent job is missing from the reports, or its ancestors form a cycle
+	Reports      int           `json:"reports"`              // number of reports of the job
+	Errors       []string      `json:"errors,omitempty"`     // errors of the reports, in timeline order
+
+	Children []*Node `json:"children,omitempty"` // sorted by StartedAt, then by JobID
+}
+
+// Tree the job trees of a set of reports
+type Tree struct {
+	Roots      []*Node  `json:"roots"`                // jobs without a parent job, followed by the orphans. Sorted by StartedAt, then by JobID
+	Orphans    []string `json:"orphans,omitempty"`    // jobIDs of the orphan jobs
+	Unfinished []string `json:"unfinished,omitempty"` // jobIDs of the jobs that did not finish, in depth first order
+
+	nodes map[string]*Node
+}
+
+/*
+Build rebuilds the job trees of the reports, linked by JobID and ParentAction. Reports without a jobID are ignored.
+
+	tree := jobtree.Build(store.Find(receiver.Query{CustomerGUID: customerGUID}))
+	for _, jobID := range tree.Unfinished { ... }
+*/
+func Build(reports []*datastructures.BaseReport) *Tree {
+	tree := &Tree{Roots: []*Node{}, nodes: map[string]*Node{}}
+	timelines := map[string][]*datastructures.BaseReport{}
+	order := []string{}
+	for _, report := range reports {
+		if report.JobID == "" {
+			continue
+		}
+		if _, ok := timelines[report.JobID]; !ok {
+			order = append(order, report.JobID)
+		}
+		timelines[report.JobID] = append(timelines[report.JobID], report)
+	}
+	for _, jobID := range order {
+		tree.nodes[jobID] = newNode(jobID, timelines[jobID])
+	}
+
+	for _, jobID := range order {
+		node := tree.nodes[jobID]
+		if node.ParentJobID == "" {
+			tree.Roots = append(tree.Roots, node)
+		} else if parent, ok := tree.nodes[node.ParentJobID]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+	sortNodes(tree.Roots)
+	visited := map[string]bool{}
+	for _, root := range tree.Roots {
+		markVisited(root, visited)
+	}
+	// what was not reached from a root has a missing parent, or is part of a cycle
+	orphans := []*Node{}
+	for _, jobID := range order {
+		node := tree.nodes[jobID]
+		if visited[jobID] || !isOrphanRoot(node, tree.nodes, visited) {
+			continue
+		}
+		node.Orphan = true
+		orphans = append(orphans, node)
+		markVisited(node, visited)
+	}
+	sortNodes(orphans)
+	for _, node := range orphans {
+		tree.Roots = append(tree.Roots, node)
+		tree.Orphans = append(tree.Orphans, node.JobID)
+	}
+
+	for _, root := range tree.Roots {
+		aggregate(root)
+		tree.collectUnfinished(root)
+	}
+	return tree
+}
+
+// isOrphanRoot returns true if the node is the top of an unreached branch - its parent is missing, or the node is part
+// of a cycle of parent jobs
+func isOrphanRoot(node *Node, nodes map[string]*Node, visited map[string]bool) bool {
+	if _, ok := nodes[node.ParentJobID]; !ok {
+		return true
+	}
+	seen := map[string]bool{}
+	for parent := nodes[node.ParentJobID]; parent != nil && !visited[parent.JobID]; parent = nodes[parent.ParentJobID] {
+		if parent.JobID == node.JobID {
+			return true
+		}
+		if seen[parent.JobID] {
+			return false // the node is below a cycle, it is reached from it
+		}
+		seen[parent.JobID] = true
+	}
+	return false // the top of the branch is another orphan
+}
+
+func markVisited(node *Node, visited map[string]bool) {
+	if visited[node.JobID] {
+		return
+	}
+	visited[node.JobID] = true
+	sortNodes(node.Children)
+	children := node.Children[:0]
+	for _, child := range node.Children {
+		// a child already in the tree closes a cycle, it is not a child of this node
+		if !visited[child.JobID] {
+			children = append(children, child)
+			markVisited(child, visited)
+		}
+	}
+	node.Children = children
+}
+
+func newNode(jobID string, timeline []*datastructures.BaseReport) *Node {
+	datastructures.SortTimeline(timeline)
+	node := &Node{JobID: jobID, Reports: len(timeline)}
+	startedAt := time.Time{}
+	for _, report := range timeline {
+		if node.ParentJobID == "" && report.ParentAction != jobID {
+			node.ParentJobID = report.ParentAction
+		}
+		if node.Reporter == "" {
+			node.Reporter = report.Reporter
+		}
+		if node.Target == "" {
+			node.Target = report.Target
+		}
+		if startedAt.IsZero() && report.Status == datastructures.JobStarted {
+			startedAt = report.Timestamp
+		}
+		if report.Timestamp.After(node.LastReportAt) {
+			node.LastReportAt = report.Timestamp
+		}
+		node.Errors = append(node.Errors, report.Errors...)
+	}
+	if startedAt.IsZero() {
+		startedAt = timeline[0].Timestamp
+	}
+	node.StartedAt = startedAt
+	last := timeline[len(timeline)-1]
+	node.Status = last.Status
+	switch last.Status {
+	case datastructures.JobSuccess, datastructures.JobFailed, datastructures.JobDone:
+		node.Finished = true
+		finishedAt := last.Timestamp
+		node.FinishedAt = &finishedAt
+		if finishedAt.After(node.StartedAt) {
+			node.Duration = finishedAt.Sub(node.StartedAt)
+		}
+	}
+	statuses := make([]datastructures.StatusType, len(timeline))
+	for i, report := range timeline {
+		statuses[i] = report.Status
+	}
+	node.AggregatedStatus = datastructures.AggregateStatus(statuses...)
+	return node
+}
+
+// aggregate raises the aggregated status of the node with the aggregated status of its descendants
+func aggregate(node *Node) {
+	statuses := []datastructures.StatusType{node.AggregatedStatus}
+	unfinished := !node.Finished
+	for _, child := range node.Children {
+		aggregate(child)
+		statuses = append(statuses, child.AggregatedStatus)
+		unfinished = unfinished || child.AggregatedStatus == datastructures.JobStarted
+	}
+	node.AggregatedStatus = datastructures.AggregateStatus(statuses...)
+	if node.AggregatedStatus == datastructures.JobSuccess && unfinished {
+		node.AggregatedStatus = datastructures.JobStarted
+	}
+}
+
+func (tree *Tree) collectUnfinished(node *Node) {
+	if !node.Finished {
+		tree.Unfinished = append(tree.Unfinished, node.JobID)
+	}
+	for _, child := range node.Children {
+		tree.collectUnfinished(child)
+	}
+}
+
+func sortNodes(nodes []*Node) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if !nodes[i].StartedAt.Equal(nodes[j].StartedAt) {
+			return nodes[i].StartedAt.Before(nodes[j].StartedAt)
+		}
+		return nodes[i].JobID < nodes[j].JobID
+	})
+}
+
+// Find returns the node of the job, nil if the job is not in the tree
+func (tree *Tree) Find(jobID string) *Node {
+	return tree.nodes[jobID]
+}
+
+// Walk calls fn for every node of the tree, depth first, with the depth of the node (0 for the roots)
+func (tree *Tree) Walk(fn func(node *Node, depth int)) {
+	var walk func(node *Node, depth int)
+	walk = func(node *Node, depth int) {
+		fn(node, depth)
+		for _, child := range node.Children {
+			walk(child, depth+1)
+		}
+	}
+	for _, root := range tree.Roots {
+		walk(root, 0)
+	}
+}
+
+// JSON returns the tree as indented JSON
+func (tree *Tree) JSON() ([]byte, error) {
+	return json.MarshalIndent(tree, "", "  ")
+}
@@ -0,0 +1,164 @@
+package jobtree
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/armosec/logger-go/system-reports/datastructures"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newReport(jobID, parentAction string, actionIDN int, status datastructures.StatusType, seconds int) *datastructures.BaseReport {
+	report := datastructures.NewBaseReport("a-user-guid", "attach", "", nil)
+	report.SetJobID(jobID)
+	report.SetParentAction(parentAction)
+	report.SetActionIDN(actionIDN)
+	report.SetStatus(status)
+	report.SetTimestamp(start.Add(time.Duration(seconds) * time.Second))
+	return report
+}
+
+// autoattachReports an autoattach job with 3 attach jobs - one succeeded, one warned and one never finished, a scan job
+// that failed and a job of a missing parent
+func autoattachReports() []*datastructures.BaseReport {
+	failed := newReport("scan-a", "attach-a", 2, datastructures.JobFailed, 9)
+	failed.AddError("failed to scan")
+	return []*datastructures.BaseReport{
+		newReport("attach-b", "autoattach", 1, datastructures.JobStarted, 2),
+		newReport("autoattach", "", 1, datastructures.JobStarted, 0),
+		newReport("attach-a", "autoattach", 1, datastructures.JobStarted, 1),
+		newReport("attach-a", "autoattach", 3, datastructures.JobSuccess, 5),
+		newReport("attach-a", "autoattach", 2, datastructures.JobStarted, 3),
+		newReport("attach-b", "autoattach", 2, datastructures.JobWarning, 4),
+		newReport("attach-b", "autoattach", 3, datastructures.JobSuccess, 6),
+		newReport("attach-c", "autoattach", 1, datastructures.JobStarted, 7),
+		newReport("scan-a", "attach-a", 1, datastructures.JobStarted, 8),
+		failed,
+		newReport("autoattach", "", 2, datastructures.JobDone, 10),
+		newReport("orphan", "missing-job", 1, datastructures.JobSuccess, 11),
+		newReport("", "", 1, datastructures.JobStarted, 12),
+	}
+}
+
+func TestBuild(t *testing.T) {
+	tree := Build(autoattachReports())
+
+	require.Len(t, tree.Roots, 2)
+	root := tree.Roots[0]
+	assert.Equal(t, "autoattach", root.JobID)
+	assert.Equal(t, datastructures.JobDone, root.Status)
+	assert.Equal(t, datastructures.JobFailed, root.AggregatedStatus, "a descendant failed")
+	assert.True(t, root.Finished)
+	assert.Equal(t, 10*time.Second, root.Duration)
+	assert.Equal(t, 2, root.Reports)
+
+	children := []string{}
+	for _, child := range root.Children {
+		children = append(children, child.JobID)
+	}
+	assert.Equal(t, []string{"attach-a", "attach-b", "attach-c"}, children, "sorted by start time")
+
+	attachA := tree.Find("attach-a")
+	assert.Equal(t, datastructures.JobSuccess, attachA.Status)
+	assert.Equal(t, datastructures.JobFailed, attachA.AggregatedStatus)
+	assert.Equal(t, 4*time.Second, attachA.Duration)
+	assert.Equal(t, start.Add(5*time.Second), *attachA.FinishedAt)
+	require.Len(t, attachA.Children, 1)
+
+	scan := attachA.Children[0]
+	assert.Equal(t, "scan-a", scan.JobID)
+	assert.Equal(t, datastructures.JobFailed, scan.AggregatedStatus)
+	assert.Equal(t, []string{"failed to scan"}, scan.Errors)
+
+	attachB := tree.Find("attach-b")
+	assert.Equal(t, datastructures.JobSuccess, attachB.Status)
+	assert.Equal(t, datastructures.JobWarning, attachB.AggregatedStatus, "the job warned")
+
+	attachC := tree.Find("attach-c")
+	assert.False(t, attachC.Finished)
+	assert.Nil(t, attachC.FinishedAt)
+	assert.Zero(t, attachC.Duration)
+	assert.Equal(t, datastructures.JobStarted, attachC.AggregatedStatus)
+
+	orphan := tree.Roots[1]
+	assert.Equal(t, "orphan", orphan.JobID)
+	assert.True(t, orphan.Orphan)
+	assert.Equal(t, []string{"orphan"}, tree.Orphans)
+	assert.Equal(t, []string{"attach-c"}, tree.Unfinished)
+	assert.Nil(t, tree.Find("missing-job"))
+
+	depths := map[string]int{}
+	tree.Walk(func(node *Node, depth int) { depths[node.JobID] = depth })
+	assert.Equal(t, map[string]int{"autoattach": 0, "attach-a": 1, "attach-b": 1, "attach-c": 1, "scan-a": 2, "orphan": 0}, depths)
+}
+
+func TestAggregatedStatus(t *testing.T) {
+	for name, tc := range map[string]struct {
+		parent, child []datastructures.StatusType
+		expected      datastructures.StatusType
+	}{
+		"success":           {parent: []datastructures.StatusType{"started", "success"}, child: []datastructures.StatusType{"started", "success"}, expected: "success"},
+		"child unfinished":  {parent: []datastructures.StatusType{"started", "done"}, child: []datastructures.StatusType{"started"}, expected: "started"},
+		"parent unfinished": {parent: []datastructures.StatusType{"started"}, child: []datastructures.StatusType{"started", "success"}, expected: "started"},
+		"warning":           {parent: []datastructures.StatusType{"started", "done"}, child: []datastructures.StatusType{"started", "warning"}, expected: "warning"},
+		"failure":           {parent: []datastructures.StatusType{"started", "warning"}, child: []datastructures.StatusType{"started", "failure", "started", "success"}, expected: "failure"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			reports := []*datastructures.BaseReport{}
+			for i, status := range tc.parent {
+				reports = append(reports, newReport("parent", "", i+1, status, i))
+			}
+			for i, status := range tc.child {
+				reports = append(reports, newReport("child", "parent", i+1, status, i))
+			}
+			assert.Equal(t, tc.expected, Build(reports).Find("parent").AggregatedStatus)
+		})
+	}
+}
+
+func TestBuildCycle(t *testing.T) {
+	tree := Build([]*datastructures.BaseReport{
+		newReport("below", "b", 1, datastructures.JobSuccess, 2),
+		newReport("a", "b", 1, datastructures.JobSuccess, 0),
+		newReport("b", "a", 1, datastructures.JobSuccess, 1),
+		newReport("self", "self", 1, datastructures.JobSuccess, 3),
+	})
+	require.Len(t, tree.Roots, 2)
+	assert.Equal(t, "a", tree.Roots[1].JobID, "the cycle is reported as an orphan")
+	assert.Equal(t, []string{"a"}, tree.Orphans)
+	assert.Equal(t, "self", tree.Roots[0].JobID, "a job that is its own parent is a root")
+
+	nodes := 0
+	tree.Walk(func(node *Node, depth int) { nodes++ })
+	assert.Equal(t, 4, nodes, "every job is in the tree once")
+	assert.Equal(t, "b", tree.Find("a").Children[0].JobID)
+	assert.Equal(t, "below", tree.Find("b").Children[0].JobID)
+}
+
+func TestJSON(t *testing.T) {
+	tree := Build(autoattachReports())
+	data, err := tree.JSON()
+	require.NoError(t, err)
+
+	decoded := Tree{}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Len(t, decoded.Roots, 2)
+	assert.Equal(t, "autoattach", decoded.Roots[0].JobID)
+	assert.Equal(t, datastructures.JobFailed, decoded.Roots[0].AggregatedStatus)
+	assert.Len(t, decoded.Roots[0].Children, 3)
+	assert.Equal(t, []string{"attach-c"}, decoded.Unfinished)
+
+	raw := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(data, &raw))
+	attachC := raw["roots"].([]interface{})[0].(map[string]interface{})["children"].([]interface{})[2].(map[string]interface{})
+	assert.Equal(t, "attach-c", attachC["jobID"])
+	assert.NotContains(t, attachC, "finishedAt")
+
+	empty, err := Build(nil).JSON()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"roots":[]}`, string(empty))
+}
@@ -0,0 +1,89 @@
+// log/slog, logr and glog adapters of the system reports datastructures.Logger
+package logger
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/armosec/logger-go/system-reports/datastructures"
+	"github.com/go-logr/logr"
+	"github.com/golang/glog"
+)
+
+// keysAndValues flattens the fields to the alternating key, value list of logr
+func keysAndValues(fields []datastructures.Field) []interface{} {
+	kv := make([]interface{}, 0, 2*len(fields))
+	for _, field := range fields {
+		kv = append(kv, field.Key, field.Value)
+	}
+	return kv
+}
+
+type logrLogger struct {
+	logger logr.Logger
+}
+
+// NewLogrLogger returns a Logger writing to the logr.Logger. Debug is written at verbosity 1, Warn at verbosity 0 with
+// a "level"="warning" field, since logr has no warning level
+func NewLogrLogger(logger logr.Logger) datastructures.Logger {
+	return &logrLogger{logger: logger}
+}
+
+func (l *logrLogger) Debug(msg string, fields ...datastructures.Field) {
+	l.logger.V(1).Info(msg, keysAndValues(fields)...)
+}
+
+func (l *logrLogger) Info(msg string, fields ...datastructures.Field) {
+	l.logger.Info(msg, keysAndValues(fields)...)
+}
+
+func (l *logrLogger) Warn(msg string, fields ...datastructures.Field) {
+	l.logger.Info(msg, append([]interface{}{"level", "warning"}, keysAndValues(fields)...)...)
+}
+
+func (l *logrLogger) Error(msg string, err error, fields ...datastructures.Field) {
+	l.logger.Error(err, msg, keysAndValues(fields)...)
+}
+
+type glogLogger struct{}
+
+// NewGlogLogger returns a Logger writing to glog, the way the system reports logged before the Logger was pluggable
+func NewGlogLogger() datastructures.Logger {
+	return glogLogger{}
+}
+
+func (glogLogger) Debug(msg string, fields ...datastructures.Field) {
+	if glog.V(1) {
+		glog.InfoDepth(1, formatLine(msg, nil, fields))
+	}
+}
+
+func (glogLogger) Info(msg string, fields ...datastructures.Field) {
+	glog.InfoDepth(1, formatLine(msg, nil, fields))
+}
+
+func (glogLogger) Warn(msg string, fields ...datastructures.Field) {
+	glog.WarningDepth(1, formatLine(msg, nil, fields))
+}
+
+func (glogLogger) Error(msg string, err error, fields ...datastructures.Field) {
+	glog.ErrorDepth(1, formatLine(msg, err, fields))
+}
+
+// formatLine formats a text log line, eg. `failed posting report: EOF reportID="..." attempt=1`
+func formatLine(msg string, err error, fields []datastructures.Field) string {
+	line := strings.Builder{}
+	line.WriteString(msg)
+	if err != nil {
+		line.WriteString(": ")
+		line.WriteString(err.Error())
+	}
+	for _, field := range fields {
+		if s, ok := field.Value.(string); ok {
+			fmt.Fprintf(&line, " %s=%q", field.Key, s)
+		} else {
+			fmt.Fprintf(&line, " %s=%v", field.Key, field.Value)
+		}
+	}
+	return line.String()
+}
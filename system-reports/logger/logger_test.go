@@ -0,0 +1,36 @@
+package logger
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/armosec/logger-go/system-reports/datastructures"
+	"github.com/go-logr/logr/funcr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogrLogger(t *testing.T) {
+	lines := []string{}
+	logger := NewLogrLogger(funcr.New(func(prefix, args string) {
+		lines = append(lines, args)
+	}, funcr.Options{Verbosity: 0}))
+
+	logger.Debug("hidden", datastructures.F("attempt", 1))
+	logger.Info("sent", datastructures.F(datastructures.LogFieldJobID, "job-1"))
+	logger.Warn("invalid status transition", datastructures.F(datastructures.LogFieldReportID, "report-1"))
+	logger.Error("failed posting report", errors.New("EOF"), datastructures.F(datastructures.LogFieldAttempt, 2))
+
+	assert.Equal(t, []string{
+		`"level"=0 "msg"="sent" "jobID"="job-1"`,
+		`"level"=0 "msg"="invalid status transition" "level"="warning" "reportID"="report-1"`,
+		`"msg"="failed posting report" "error"="EOF" "attempt"=2`,
+	}, lines)
+}
+
+func TestFormatLine(t *testing.T) {
+	line := formatLine("failed posting report", errors.New("EOF"), []datastructures.Field{
+		datastructures.F(datastructures.LogFieldURL, "http://receiver/k8s/sysreport"),
+		datastructures.F(datastructures.LogFieldAttempt, 2),
+	})
+	assert.Equal(t, `failed posting report: EOF url="http://receiver/k8s/sysreport" attempt=2`, line)
+}
@@ -0,0 +1,50 @@
+//go:build go1.21
+
+package logger
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/armosec/logger-go/system-reports/datastructures"
+)
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger returns a Logger writing to the slog.Logger, the error of Error is written as the "error" attribute
+func NewSlogLogger(logger *slog.Logger) datastructures.Logger {
+	return &slogLogger{logger: logger}
+}
+
+func (l *slogLogger) Debug(msg string, fields ...datastructures.Field) {
+	l.log(slog.LevelDebug, msg, fields)
+}
+
+func (l *slogLogger) Info(msg string, fields ...datastructures.Field) {
+	l.log(slog.LevelInfo, msg, fields)
+}
+
+func (l *slogLogger) Warn(msg string, fields ...datastructures.Field) {
+	l.log(slog.LevelWarn, msg, fields)
+}
+
+func (l *slogLogger) Error(msg string, err error, fields ...datastructures.Field) {
+	if err != nil {
+		fields = append(fields, datastructures.F("error", err))
+	}
+	l.log(slog.LevelError, msg, fields)
+}
+
+func (l *slogLogger) log(level slog.Level, msg string, fields []datastructures.Field) {
+	ctx := context.Background()
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+	attrs := make([]slog.Attr, len(fields))
+	for i, field := range fields {
+		attrs[i] = slog.Any(field.Key, field.Value)
+	}
+	l.logger.LogAttrs(ctx, level, msg, attrs...)
+}
@@ -0,0 +1,36 @@
+//go:build go1.21
+
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/armosec/logger-go/system-reports/datastructures"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogLogger(t *testing.T) {
+	buf := bytes.Buffer{}
+	logger := NewSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+
+	logger.Debug("hidden")
+	logger.Warn("invalid status transition", datastructures.F(datastructures.LogFieldReportID, "report-1"))
+	logger.Error("failed posting report", errors.New("EOF"), datastructures.F(datastructures.LogFieldAttempt, 2))
+
+	assert.Equal(t, []string{
+		`level=WARN msg="invalid status transition" reportID=report-1`,
+		`level=ERROR msg="failed posting report" attempt=2 error=EOF`,
+	}, strings.Split(strings.TrimSpace(buf.String()), "\n"))
+}
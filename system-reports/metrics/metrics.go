@@ -0,0 +1,141 @@
+// Prometheus metrics of system report delivery
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/armosec/logger-go/system-reports/datastructures"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const defaultNamespace = "sysreport"
+
+// Config Metrics configuration
+type Config struct {
+	Namespace       string    // metric name prefix, default "sysreport"
+	DurationBuckets []float64 // send duration histogram buckets in seconds, default prometheus.DefBuckets
+}
+
+/*
+Metrics a datastructures.MetricsRecorder exposing the delivery of reports as Prometheus metrics:
+
+	sysreport_reports_sent_total{reporter,status,code}    reports delivered
+	sysreport_reports_failed_total{reporter,status,code}  reports that were not delivered
+	sysreport_retries_total{reporter,code}                retries
+	sysreport_reports_dropped_total{reporter,reason}      reports that were not delivered nor kept in an outbox
+	sysreport_errchan_dropped_total                       errors nobody read from the error channel
+	sysreport_send_duration_seconds{reporter}             send duration, retries included
+	sysreport_send_attempts{reporter}                     attempts per report
+	sysreport_outbox_size_bytes                           size of the outbox, see RegisterOutbox
+
+code is the http status code of the last attempt, "0" if there was no response.
+
+	m, err := metrics.New(prometheus.DefaultRegisterer, metrics.Config{})
+	datastructures.SetMetricsRecorder(m)
+*/
+type Metrics struct {
+	registerer prometheus.Registerer
+	namespace  string
+
+	sent           *prometheus.CounterVec
+	failed         *prometheus.CounterVec
+	retries        *prometheus.CounterVec
+	dropped        *prometheus.CounterVec
+	errChanDropped prometheus.Counter
+	sendDuration   *prometheus.HistogramVec
+	sendAttempts   *prometheus.HistogramVec
+}
+
+var _ datastructures.MetricsRecorder = (*Metrics)(nil)
+
+// New creates the metrics and registers them on the registerer
+func New(registerer prometheus.Registerer, config Config) (*Metrics, error) {
+	if config.Namespace == "" {
+		config.Namespace = defaultNamespace
+	}
+	if len(config.DurationBuckets) == 0 {
+		config.DurationBuckets = prometheus.DefBuckets
+	}
+	m := &Metrics{
+		registerer: registerer,
+		namespace:  config.Namespace,
+		sent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Name:      "reports_sent_total",
+			Help:      "Reports delivered, by reporter, report status and http status code.",
+		}, []string{"reporter", "status", "code"}),
+		failed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Name:      "reports_failed_total",
+			Help:      "Reports that were not delivered, by reporter, report status and http status code of the last attempt.",
+		}, []string{"reporter", "status", "code"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Name:      "retries_total",
+			Help:      "Report delivery retries, by reporter and http status code of the failed attempt.",
+		}, []string{"reporter", "code"}),
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Name:      "reports_dropped_total",
+			Help:      "Reports that were not delivered nor kept in an outbox, by reporter and reason.",
+		}, []string{"reporter", "reason"}),
+		errChanDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Name:      "errchan_dropped_total",
+			Help:      "Send errors that were dropped since nobody read the error channel.",
+		}),
+		sendDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: config.Namespace,
+			Name:      "send_duration_seconds",
+			Help:      "Duration of sending a report, retries included.",
+			Buckets:   config.DurationBuckets,
+		}, []string{"reporter"}),
+		sendAttempts: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: config.Namespace,
+			Name:      "send_attempts",
+			Help:      "Delivery attempts per report.",
+			Buckets:   []float64{1, 2, 3, 5, 8, 13},
+		}, []string{"reporter"}),
+	}
+	for _, collector := range []prometheus.Collector{m.sent, m.failed, m.retries, m.dropped, m.errChanDropped, m.sendDuration, m.sendAttempts} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// RegisterOutbox registers the sysreport_outbox_size_bytes gauge, reporting the size of the outbox (eg. outbox.FileOutbox)
+func (m *Metrics) RegisterOutbox(outbox interface{ Size() int64 }) error {
+	return m.registerer.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: m.namespace,
+		Name:      "outbox_size_bytes",
+		Help:      "Size of the reports kept in the outbox.",
+	}, func() float64 {
+		return float64(outbox.Size())
+	}))
+}
+
+func (m *Metrics) ObserveSend(reporter string, status datastructures.StatusType, statusCode int, attempts int, duration time.Duration, err error) {
+	code := strconv.Itoa(statusCode)
+	if err != nil {
+		m.failed.WithLabelValues(reporter, string(status), code).Inc()
+	} else {
+		m.sent.WithLabelValues(reporter, string(status), code).Inc()
+	}
+	m.sendDuration.WithLabelValues(reporter).Observe(duration.Seconds())
+	m.sendAttempts.WithLabelValues(reporter).Observe(float64(attempts))
+}
+
+func (m *Metrics) ObserveRetry(reporter string, statusCode int) {
+	m.retries.WithLabelValues(reporter, strconv.Itoa(statusCode)).Inc()
+}
+
+func (m *Metrics) ObserveDrop(reporter string, reason string) {
+	m.dropped.WithLabelValues(reporter, reason).Inc()
+}
+
+func (m *Metrics) ObserveErrChanDrop() {
+	m.errChanDropped.Inc()
+}
@@ -0,0 +1,88 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/armosec/logger-go/system-reports/datastructures"
+	"github.com/armosec/logger-go/system-reports/metrics"
+	"github.com/armosec/logger-go/system-reports/sysreporttest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type outboxSize int64
+
+func (o outboxSize) Size() int64 { return int64(o) }
+
+func TestMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m, err := metrics.New(registry, metrics.Config{})
+	require.NoError(t, err)
+	require.NoError(t, m.RegisterOutbox(outboxSize(1024)))
+	datastructures.SetMetricsRecorder(m)
+	defer datastructures.SetMetricsRecorder(nil)
+
+	server := sysreporttest.NewServer()
+	defer server.Close()
+	reporter := datastructures.NewBaseReport("a-user-guid", "my-reporter", server.URL, server.Client())
+	reporter.SetRetryPolicy(&datastructures.ConstantRetryPolicy{MaxAttempts: 2})
+
+	_, _, err = reporter.Send()
+	require.NoError(t, err)
+
+	server.FailNext(1, 503)
+	_, _, err = reporter.Send()
+	require.NoError(t, err, "delivered on the 2nd attempt")
+
+	server.FailNext(2, 503)
+	_, _, err = reporter.Send()
+	assert.Error(t, err)
+
+	server.FailNext(1, 400)
+	_, _, err = reporter.Send()
+	assert.Error(t, err)
+
+	// nobody reads the error channel
+	reporter.SendAsRoutine(false, make(chan error))
+	time.Sleep(time.Second)
+
+	expected := `
+# HELP sysreport_errchan_dropped_total Send errors that were dropped since nobody read the error channel.
+# TYPE sysreport_errchan_dropped_total counter
+sysreport_errchan_dropped_total 1
+# HELP sysreport_outbox_size_bytes Size of the reports kept in the outbox.
+# TYPE sysreport_outbox_size_bytes gauge
+sysreport_outbox_size_bytes 1024
+# HELP sysreport_reports_dropped_total Reports that were not delivered nor kept in an outbox, by reporter and reason.
+# TYPE sysreport_reports_dropped_total counter
+sysreport_reports_dropped_total{reason="no_outbox",reporter="my-reporter"} 1
+sysreport_reports_dropped_total{reason="rejected",reporter="my-reporter"} 1
+# HELP sysreport_reports_failed_total Reports that were not delivered, by reporter, report status and http status code of the last attempt.
+# TYPE sysreport_reports_failed_total counter
+sysreport_reports_failed_total{code="400",reporter="my-reporter",status="started"} 1
+sysreport_reports_failed_total{code="503",reporter="my-reporter",status="started"} 1
+# HELP sysreport_reports_sent_total Reports delivered, by reporter, report status and http status code.
+# TYPE sysreport_reports_sent_total counter
+sysreport_reports_sent_total{code="200",reporter="my-reporter",status="started"} 3
+# HELP sysreport_retries_total Report delivery retries, by reporter and http status code of the failed attempt.
+# TYPE sysreport_retries_total counter
+sysreport_retries_total{code="503",reporter="my-reporter"} 2
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"sysreport_errchan_dropped_total",
+		"sysreport_outbox_size_bytes",
+		"sysreport_reports_dropped_total",
+		"sysreport_reports_failed_total",
+		"sysreport_reports_sent_total",
+		"sysreport_retries_total",
+	))
+	assert.Equal(t, 1, testutil.CollectAndCount(registry, "sysreport_send_duration_seconds"))
+	assert.Equal(t, 1, testutil.CollectAndCount(registry, "sysreport_send_attempts"))
+
+	_, err = metrics.New(registry, metrics.Config{})
+	assert.Error(t, err, "the metrics are registered already")
+}
@@ -0,0 +1,107 @@
+package otlp
+
+// The OTLP/JSON trace model (opentelemetry-proto trace/v1, JSON encoding). Trace and span IDs are hex encoded and
+// 64 bit integers are strings, as the OTLP/JSON encoding requires
+
+// ExportTraceServiceRequest the body posted to <collector>/v1/traces
+type ExportTraceServiceRequest struct {
+	ResourceSpans []ResourceSpans `json:"resourceSpans"`
+}
+
+// ResourceSpans the spans of a single resource (service)
+type ResourceSpans struct {
+	Resource   Resource     `json:"resource"`
+	ScopeSpans []ScopeSpans `json:"scopeSpans"`
+}
+
+// Resource the entity producing the spans
+type Resource struct {
+	Attributes []KeyValue `json:"attributes,omitempty"`
+}
+
+// ScopeSpans the spans of a single instrumentation scope
+type ScopeSpans struct {
+	Scope Scope  `json:"scope"`
+	Spans []Span `json:"spans"`
+}
+
+// Scope the instrumentation scope
+type Scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// SpanKind the span kind, the adapter only produces SpanKindInternal spans
+type SpanKind int
+
+const SpanKindInternal SpanKind = 1
+
+// Span a finished span
+type Span struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              SpanKind   `json:"kind"`
+	StartTimeUnixNano uint64     `json:"startTimeUnixNano,string"`
+	EndTimeUnixNano   uint64     `json:"endTimeUnixNano,string"`
+	Attributes        []KeyValue `json:"attributes,omitempty"`
+	Events            []Event    `json:"events,omitempty"`
+	Status            Status     `json:"status"`
+}
+
+// Event a span event
+type Event struct {
+	TimeUnixNano uint64     `json:"timeUnixNano,string"`
+	Name         string     `json:"name"`
+	Attributes   []KeyValue `json:"attributes,omitempty"`
+}
+
+// StatusCode the span status code
+type StatusCode int
+
+const (
+	StatusCodeUnset StatusCode = 0
+	StatusCodeOk    StatusCode = 1
+	StatusCodeError StatusCode = 2
+)
+
+// Status the span status
+type Status struct {
+	Code    StatusCode `json:"code,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+// KeyValue an attribute, the adapter only produces string attributes
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+// AnyValue an attribute value
+type AnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// Attribute returns the value of the span attribute, false if the span has no such attribute
+func (span *Span) Attribute(key string) (string, bool) {
+	return attribute(span.Attributes, key)
+}
+
+// Attribute returns the value of the event attribute, false if the event has no such attribute
+func (event *Event) Attribute(key string) (string, bool) {
+	return attribute(event.Attributes, key)
+}
+
+func attribute(attributes []KeyValue, key string) (string, bool) {
+	for i := range attributes {
+		if attributes[i].Key == key {
+			return attributes[i].Value.StringValue, true
+		}
+	}
+	return "", false
+}
+
+func stringAttribute(key, value string) KeyValue {
+	return KeyValue{Key: key, Value: AnyValue{StringValue: value}}
+}
@@ -0,0 +1,482 @@
+// OpenTelemetry (OTLP) adapter for system reports
+package otlp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/armosec/logger-go/system-reports/datastructures"
+	"github.com/armosec/utils-go/httputils"
+)
+
+const (
+	tracesPath           = "/v1/traces"
+	scopeName            = "github.com/armosec/logger-go/system-reports"
+	defaultMaxPending    = 2048
+	defaultMaxOpenJobs   = 10000
+	defaultExportTimeout = 10 * time.Second
+)
+
+// span and event attributes
+const (
+	AttrCustomerGUID     = "armo.customer_guid"
+	AttrReporter         = "armo.reporter"
+	AttrTarget           = "armo.target"
+	AttrJobID            = "armo.job_id"
+	AttrParentJobID      = "armo.parent_job_id"
+	AttrActionID         = "armo.action_id"
+	AttrStatus           = "armo.status"
+	AttrDetails          = "armo.details"
+	AttrServiceName      = "service.name"
+	AttrExceptionMessage = "exception.message"
+	AttrExceptionType    = "exception.type"
+	EventException       = "exception"
+	EventWarning         = "warning"
+)
+
+// Config Transport configuration
+type Config struct {
+	Endpoint      string                // OTLP/HTTP url of the collector, eg. http://otel-collector:4318. Spans are posted to <Endpoint>/v1/traces
+	HttpClient    httputils.IHttpClient // http client
+	Headers       map[string]string     // additional headers of the export requests, eg. authorization
+	ServiceName   string                // service.name of the resource, default the reporter of the report
+	MaxPending    int                   // finished spans kept while the collector is unavailable, default 2048
+	ExportTimeout time.Duration         // timeout of the exports Deliver starts in the background, default 10s
+	ErrorHandler  func(error)           // called with export errors, default logs them
+}
+
+/*
+Transport a datastructures.Transport mapping the report lifecycle onto OTLP spans, instead of posting the reports to the
+event receiver.
+
+  - the first report of a job opens a span, named after its action
+  - every report adds a span event named after its action (SendAction, SendDetails...)
+  - new errors of the report are recorded as "exception" events, new warnings as "warning" events
+  - "success" and "done" end the span with an Ok status, "failure" with an Error status, and export it in the background
+
+A job going on after its span ended (eg. the next action after "success") opens a new span in the same trace. The span
+joins the trace of the W3C trace context of the report (see BaseReport.SetTraceContext), else the trace of the parent
+job, else it starts a new trace. There is no event receiver, so the transport assigns the jobIDs.
+
+Up to 10000 jobs are tracked, past that the jobs that were idle the longest are forgotten and their open spans end with
+an unset status.
+
+	transport := otlp.NewTransport(otlp.Config{Endpoint: "http://otel-collector:4318", HttpClient: &http.Client{}})
+	defer transport.Shutdown(context.Background())
+	reporter.SetTransport(transport)
+*/
+type Transport struct {
+	config Config
+
+	mu          sync.Mutex
+	jobs        map[string]*job
+	pending     []pendingSpan
+	dropped     int  // spans dropped since the ErrorHandler was last told
+	flushing    bool // a background flush is running
+	flushQueued bool // spans ended while the background flush was running
+	sequence    uint64
+
+	exportMu   sync.Mutex
+	background sync.WaitGroup
+}
+
+type pendingSpan struct {
+	service string
+	span    Span
+}
+
+// job the trace state of a single jobID
+type job struct {
+	traceID    string
+	parentID   string // span ID of the parent of the job spans
+	lastSpanID string
+	service    string
+	errorsSeen int // errors of the report that were recorded already
+	span       *Span
+	lastSeen   uint64 // sequence number of the last report of the job
+}
+
+// NewTransport returns an OTLP transport
+func NewTransport(config Config) *Transport {
+	if config.MaxPending <= 0 {
+		config.MaxPending = defaultMaxPending
+	}
+	if config.ExportTimeout <= 0 {
+		config.ExportTimeout = defaultExportTimeout
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = func(err error) {
+			datastructures.NewRedactingLogger(datastructures.GetLogger(), datastructures.GetRedactor()).Error("failed to export system report spans", err)
+		}
+	}
+	return &Transport{config: config, jobs: map[string]*job{}}
+}
+
+// Deliver applies the report to the span of its job. The spans that ended are exported in the background, export errors
+// do not fail the delivery, they are passed to the ErrorHandler and the spans are exported again with the next export
+func (t *Transport) Deliver(ctx context.Context, report []byte) (datastructures.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return datastructures.Result{}, err
+	}
+	r := datastructures.BaseReport{}
+	if err := json.Unmarshal(report, &r); err != nil {
+		return datastructures.Result{StatusCode: 400}, fmt.Errorf("failed to decode report: %w", err)
+	}
+	body := "ok"
+	if r.JobID == "" {
+		jobID, err := datastructures.NewJobID()
+		if err != nil {
+			return datastructures.Result{}, err
+		}
+		r.JobID = jobID
+		body = jobID
+	}
+	traceContext, _ := datastructures.TraceContextFromContext(ctx)
+
+	t.mu.Lock()
+	ended := t.apply(&r, traceContext)
+	t.mu.Unlock()
+	t.reportDropped()
+
+	if ended {
+		t.flushInBackground()
+	}
+	return datastructures.Result{StatusCode: 200, Body: body}, nil
+}
+
+func (t *Transport) String() string {
+	return "otlp+" + t.config.Endpoint + tracesPath
+}
+
+// flushInBackground exports the finished spans without holding up the delivery. Spans ending while a background flush
+// runs are exported by that flush once it is done
+func (t *Transport) flushInBackground() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.flushing {
+		t.flushQueued = true
+		return
+	}
+	t.flushing = true
+	t.background.Add(1)
+	go func() {
+		defer t.background.Done()
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), t.config.ExportTimeout)
+			if err := t.Flush(ctx); err != nil {
+				t.config.ErrorHandler(err)
+			}
+			cancel()
+
+			t.mu.Lock()
+			if !t.flushQueued {
+				t.flushing = false
+				t.mu.Unlock()
+				return
+			}
+			t.flushQueued = false
+			t.mu.Unlock()
+		}
+	}()
+}
+
+// Flush exports the finished spans, waiting for a background export in progress first
+func (t *Transport) Flush(ctx context.Context) error {
+	t.exportMu.Lock()
+	defer t.exportMu.Unlock()
+
+	t.mu.Lock()
+	spans := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+	if err := t.export(ctx, spans); err != nil {
+		t.mu.Lock()
+		t.pending = append(spans, t.pending...)
+		t.trimPending()
+		t.mu.Unlock()
+		t.reportDropped()
+		return err
+	}
+	return nil
+}
+
+// Shutdown ends the open spans with an unset status, waits for the background exports and exports all the spans
+func (t *Transport) Shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	now := time.Now()
+	for jobID, j := range t.jobs {
+		if j.span != nil {
+			t.end(j, now, Status{})
+		}
+		delete(t.jobs, jobID)
+	}
+	t.mu.Unlock()
+	t.reportDropped()
+
+	done := make(chan struct{})
+	go func() {
+		t.background.Wait()
+		close(done)
+	}()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-done:
+	}
+	return t.Flush(ctx)
+}
+
+// apply applies the report to the span of its job, returns true if the span ended
+func (t *Transport) apply(r *datastructures.BaseReport, traceContext datastructures.TraceContext) bool {
+	j, ok := t.jobs[r.JobID]
+	if !ok {
+		j = t.newJob(r, traceContext)
+		t.jobs[r.JobID] = j
+	}
+	t.sequence++
+	j.lastSeen = t.sequence
+	if j.span == nil {
+		t.start(j, r)
+	}
+	t.recordErrors(j, r)
+
+	event := Event{
+		TimeUnixNano: unixNano(r.Timestamp),
+		Name:         r.ActionName,
+		Attributes: []KeyValue{
+			stringAttribute(AttrActionID, r.ActionID),
+			stringAttribute(AttrStatus, string(r.Status)),
+		},
+	}
+	if r.Details != "" {
+		event.Attributes = append(event.Attributes, stringAttribute(AttrDetails, r.Details))
+	}
+	j.span.Events = append(j.span.Events, event)
+
+	switch r.Status {
+	case datastructures.JobSuccess, datastructures.JobDone:
+		t.end(j, r.Timestamp, Status{Code: StatusCodeOk})
+	case datastructures.JobFailed:
+		t.end(j, r.Timestamp, Status{Code: StatusCodeError, Message: lastError(r)})
+	default:
+		return false
+	}
+	if r.Status == datastructures.JobDone {
+		delete(t.jobs, r.JobID)
+	}
+	return true
+}
+
+func (t *Transport) newJob(r *datastructures.BaseReport, traceContext datastructures.TraceContext) *job {
+	j := &job{service: t.config.ServiceName}
+	if j.service == "" {
+		j.service = r.Reporter
+	}
+	if traceContext.IsValid() {
+		j.traceID, j.parentID = traceContext.TraceID, traceContext.SpanID
+	} else if parent, ok := t.jobs[r.ParentAction]; ok && r.ParentAction != "" {
+		j.traceID, j.parentID = parent.traceID, parent.lastSpanID
+	} else {
+		j.traceID = randomHex(16)
+	}
+	if len(t.jobs) >= defaultMaxOpenJobs {
+		t.evictJobs()
+	}
+	return j
+}
+
+// evictJobs forgets the jobs without an open span, and if there are still too many jobs, the tenth of the jobs that were
+// idle the longest - ending their spans with an unset status. A job going on afterwards starts a new trace
+func (t *Transport) evictJobs() {
+	for jobID, other := range t.jobs {
+		if other.span == nil {
+			delete(t.jobs, jobID)
+		}
+	}
+	if len(t.jobs) < defaultMaxOpenJobs {
+		return
+	}
+	jobIDs := make([]string, 0, len(t.jobs))
+	for jobID := range t.jobs {
+		jobIDs = append(jobIDs, jobID)
+	}
+	sort.Slice(jobIDs, func(a, b int) bool {
+		return t.jobs[jobIDs[a]].lastSeen < t.jobs[jobIDs[b]].lastSeen
+	})
+	now := time.Now()
+	for _, jobID := range jobIDs[:len(jobIDs)-defaultMaxOpenJobs*9/10] {
+		t.end(t.jobs[jobID], now, Status{})
+		delete(t.jobs, jobID)
+	}
+}
+
+func (t *Transport) start(j *job, r *datastructures.BaseReport) {
+	name := r.ActionName
+	if name == "" {
+		name = r.Reporter
+	}
+	j.lastSpanID = randomHex(8)
+	j.span = &Span{
+		TraceID:           j.traceID,
+		SpanID:            j.lastSpanID,
+		ParentSpanID:      j.parentID,
+		Name:              name,
+		Kind:              SpanKindInternal,
+		StartTimeUnixNano: unixNano(r.Timestamp),
+		Attributes: []KeyValue{
+			stringAttribute(AttrCustomerGUID, r.CustomerGUID),
+			stringAttribute(AttrReporter, r.Reporter),
+			stringAttribute(AttrTarget, r.Target),
+			stringAttribute(AttrJobID, r.JobID),
+		},
+	}
+	if r.ParentAction != "" {
+		j.span.Attributes = append(j.span.Attributes, stringAttribute(AttrParentJobID, r.ParentAction))
+	}
+}
+
+// recordErrors records the errors added since the previous report, the errors of a report only grow until they are
+// reset (see BaseReport.SendError initErrors)
+func (t *Transport) recordErrors(j *job, r *datastructures.BaseReport) {
+	details := r.ErrorDetails
+	if len(details) == 0 {
+		for _, e := range r.Errors {
+			details = append(details, datastructures.ErrorDetail{Severity: datastructures.SeverityError, Message: e, Timestamp: r.Timestamp})
+		}
+	}
+	if len(details) < j.errorsSeen {
+		j.errorsSeen = 0
+	}
+	for _, detail := range details[j.errorsSeen:] {
+		name := EventException
+		if detail.Severity == datastructures.SeverityWarning {
+			name = EventWarning
+		}
+		errorType := detail.Code
+		if errorType == "" {
+			errorType = string(detail.Severity)
+		}
+		timestamp := detail.Timestamp
+		if timestamp.IsZero() {
+			timestamp = r.Timestamp
+		}
+		j.span.Events = append(j.span.Events, Event{
+			TimeUnixNano: unixNano(timestamp),
+			Name:         name,
+			Attributes: []KeyValue{
+				stringAttribute(AttrExceptionType, errorType),
+				stringAttribute(AttrExceptionMessage, detail.Message),
+				stringAttribute(AttrActionID, detail.ActionID),
+			},
+		})
+	}
+	j.errorsSeen = len(details)
+}
+
+// end ends the open span of the job and queues it for export
+func (t *Transport) end(j *job, endTime time.Time, status Status) {
+	j.span.EndTimeUnixNano = unixNano(endTime)
+	if j.span.EndTimeUnixNano < j.span.StartTimeUnixNano {
+		j.span.EndTimeUnixNano = j.span.StartTimeUnixNano
+	}
+	j.span.Status = status
+	t.pending = append(t.pending, pendingSpan{service: j.service, span: *j.span})
+	j.span = nil
+	t.trimPending()
+}
+
+// trimPending drops the oldest spans once there are more than MaxPending, call reportDropped once t.mu is unlocked
+func (t *Transport) trimPending() {
+	if over := len(t.pending) - t.config.MaxPending; over > 0 {
+		t.dropped += over
+		t.pending = append([]pendingSpan{}, t.pending[over:]...)
+	}
+}
+
+// reportDropped passes the spans trimPending dropped to the ErrorHandler
+func (t *Transport) reportDropped() {
+	t.mu.Lock()
+	dropped := t.dropped
+	t.dropped = 0
+	t.mu.Unlock()
+	if dropped > 0 {
+		t.config.ErrorHandler(fmt.Errorf("dropped %d spans, the export queue is full", dropped))
+	}
+}
+
+func (t *Transport) export(ctx context.Context, spans []pendingSpan) error {
+	// a resource per service, keeping the order of the spans
+	request := ExportTraceServiceRequest{}
+	index := map[string]int{}
+	for _, pending := range spans {
+		service, span := pending.service, pending.span
+		i, ok := index[service]
+		if !ok {
+			i = len(request.ResourceSpans)
+			index[service] = i
+			request.ResourceSpans = append(request.ResourceSpans, ResourceSpans{
+				Resource:   Resource{Attributes: []KeyValue{stringAttribute(AttrServiceName, service)}},
+				ScopeSpans: []ScopeSpans{{Scope: Scope{Name: scopeName}}},
+			})
+		}
+		request.ResourceSpans[i].ScopeSpans[0].Spans = append(request.ResourceSpans[i].ScopeSpans[0].Spans, span)
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal spans: %w", err)
+	}
+	headers := map[string]string{"Content-Type": "application/json"}
+	for k, v := range t.config.Headers {
+		headers[k] = v
+	}
+	resp, err := httputils.HttpPostWithContext(ctx, t.config.HttpClient, strings.TrimSuffix(t.config.Endpoint, "/")+tracesPath, headers, body)
+	if err != nil {
+		return fmt.Errorf("failed to export %d spans: %w", len(spans), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to export %d spans, status: %d, response: %s", len(spans), resp.StatusCode, respBody)
+	}
+	return nil
+}
+
+func lastError(r *datastructures.BaseReport) string {
+	for i := len(r.ErrorDetails) - 1; i >= 0; i-- {
+		if r.ErrorDetails[i].Severity == datastructures.SeverityError {
+			return r.ErrorDetails[i].Message
+		}
+	}
+	if len(r.Errors) > 0 {
+		return r.Errors[len(r.Errors)-1]
+	}
+	return ""
+}
+
+func unixNano(t time.Time) uint64 {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return uint64(t.UnixNano())
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
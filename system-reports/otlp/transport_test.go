@@ -0,0 +1,209 @@
+package otlp_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/armosec/logger-go/system-reports/datastructures"
+	"github.com/armosec/logger-go/system-reports/otlp"
+	"github.com/armosec/logger-go/system-reports/sysreporttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newReporter(transport datastructures.Transport) *datastructures.BaseReport {
+	reporter := datastructures.NewBaseReport("a-user-guid", "my-reporter", "", nil)
+	reporter.SetTransport(transport)
+	reporter.SetRetryPolicy(datastructures.NoRetryPolicy{})
+	return reporter
+}
+
+func eventNames(span otlp.Span) []string {
+	names := []string{}
+	for _, event := range span.Events {
+		names = append(names, event.Name)
+	}
+	return names
+}
+
+func TestTransportLifecycle(t *testing.T) {
+	collector := sysreporttest.NewOTLPCollector()
+	defer collector.Close()
+	transport := otlp.NewTransport(otlp.Config{Endpoint: collector.URL, HttpClient: collector.Client()})
+
+	reporter := newReporter(transport)
+	errChan := make(chan error)
+	reporter.SendAsRoutine(true, errChan)
+	require.NoError(t, <-errChan)
+	assert.NotEmpty(t, reporter.GetJobID())
+	reporter.SendAction("scanning workload", true, errChan)
+	require.NoError(t, <-errChan)
+	assert.Empty(t, collector.Spans(), "the span is exported once it ends")
+
+	reporter.SendWarning("slow registry", true, false, errChan)
+	require.NoError(t, <-errChan)
+	reporter.SendError(errors.New("registry unreachable"), true, false, errChan)
+	require.NoError(t, <-errChan)
+	require.NoError(t, transport.Flush(context.Background()), "the ended span is exported in the background")
+
+	spans := collector.Spans()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "Starting my-reporter", span.Name)
+	assert.Len(t, span.TraceID, 32)
+	assert.Len(t, span.SpanID, 16)
+	assert.Empty(t, span.ParentSpanID)
+	assert.GreaterOrEqual(t, span.EndTimeUnixNano, span.StartTimeUnixNano)
+	jobID, _ := span.Attribute(otlp.AttrJobID)
+	assert.Equal(t, reporter.GetJobID(), jobID)
+	assert.Equal(t, otlp.StatusCodeError, span.Status.Code)
+	assert.Equal(t, "registry unreachable", span.Status.Message)
+	assert.Equal(t, []string{"Starting my-reporter", "scanning workload", otlp.EventWarning, "scanning workload", otlp.EventException, "scanning workload"}, eventNames(span))
+	message, _ := span.Events[4].Attribute(otlp.AttrExceptionMessage)
+	assert.Equal(t, "registry unreachable", message)
+
+	service, _ := attribute(collector.Exports()[0].ResourceSpans[0].Resource.Attributes, otlp.AttrServiceName)
+	assert.Equal(t, "my-reporter", service)
+
+	// the job goes on in a new span of the same trace
+	reporter.SetStatus(datastructures.JobStarted)
+	reporter.SendAction("retrying", true, errChan)
+	require.NoError(t, <-errChan)
+	reporter.SendStatus(datastructures.JobDone, true, errChan)
+	require.NoError(t, <-errChan)
+	require.NoError(t, transport.Flush(context.Background()))
+	spans = collector.TraceSpans(span.TraceID)
+	require.Len(t, spans, 2)
+	assert.Equal(t, "retrying", spans[1].Name)
+	assert.Equal(t, otlp.StatusCodeOk, spans[1].Status.Code)
+	assert.Equal(t, []string{"retrying", "retrying"}, eventNames(spans[1]), "errors that were recorded are not recorded again")
+}
+
+func TestTransportTraceContext(t *testing.T) {
+	collector := sysreporttest.NewOTLPCollector()
+	defer collector.Close()
+	transport := otlp.NewTransport(otlp.Config{Endpoint: collector.URL, HttpClient: collector.Client(), ServiceName: "kubescape"})
+	tc, err := datastructures.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+
+	reporter := newReporter(transport)
+	reporter.SetTraceContext(tc)
+	errChan := make(chan error)
+	reporter.SendStatus(datastructures.JobSuccess, true, errChan)
+	require.NoError(t, <-errChan)
+	require.NoError(t, transport.Flush(context.Background()))
+
+	spans := collector.TraceSpans(tc.TraceID)
+	require.Len(t, spans, 1)
+	assert.Equal(t, tc.SpanID, spans[0].ParentSpanID)
+	service, _ := attribute(collector.Exports()[0].ResourceSpans[0].Resource.Attributes, otlp.AttrServiceName)
+	assert.Equal(t, "kubescape", service)
+}
+
+func TestTransportJobTree(t *testing.T) {
+	collector := sysreporttest.NewOTLPCollector()
+	defer collector.Close()
+	transport := otlp.NewTransport(otlp.Config{Endpoint: collector.URL, HttpClient: collector.Client()})
+
+	ctx := context.Background()
+	root := datastructures.NewJob(newReporter(transport))
+	require.NoError(t, root.Start(ctx))
+	wg := sync.WaitGroup{}
+	for _, target := range []string{"wlid-1", "wlid-2"} {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			child, err := root.StartChild(ctx, "attach", target)
+			assert.NoError(t, err)
+			assert.NoError(t, child.Finish(ctx, datastructures.JobSuccess, false))
+		}(target)
+	}
+	wg.Wait()
+	require.NoError(t, root.Finish(ctx, datastructures.JobDone, true))
+	require.NoError(t, transport.Flush(ctx))
+
+	spans := collector.Spans()
+	require.Len(t, spans, 3)
+	rootSpan := spans[2]
+	rootJobID, _ := rootSpan.Attribute(otlp.AttrJobID)
+	assert.Equal(t, root.Report().GetJobID(), rootJobID)
+	for _, childSpan := range spans[:2] {
+		assert.Equal(t, rootSpan.TraceID, childSpan.TraceID)
+		assert.Equal(t, rootSpan.SpanID, childSpan.ParentSpanID)
+		parentJobID, _ := childSpan.Attribute(otlp.AttrParentJobID)
+		assert.Equal(t, rootJobID, parentJobID)
+	}
+}
+
+func TestTransportCollectorUnavailable(t *testing.T) {
+	collector := sysreporttest.NewOTLPCollector()
+	defer collector.Close()
+	exportErrors := make(chan error, 10)
+	transport := otlp.NewTransport(otlp.Config{
+		Endpoint:     collector.URL,
+		HttpClient:   collector.Client(),
+		ErrorHandler: func(err error) { exportErrors <- err },
+	})
+	collector.FailNext(1, 503)
+
+	reporter := newReporter(transport)
+	errChan := make(chan error)
+	reporter.SendStatus(datastructures.JobSuccess, true, errChan)
+	require.NoError(t, <-errChan, "export errors do not fail the report")
+	assert.Error(t, <-exportErrors)
+	assert.Empty(t, collector.Spans())
+
+	require.NoError(t, transport.Flush(context.Background()))
+	assert.Len(t, collector.Spans(), 1)
+}
+
+func TestTransportShutdown(t *testing.T) {
+	collector := sysreporttest.NewOTLPCollector()
+	defer collector.Close()
+	transport := otlp.NewTransport(otlp.Config{Endpoint: collector.URL, HttpClient: collector.Client()})
+
+	reporter := newReporter(transport)
+	errChan := make(chan error)
+	reporter.SendAsRoutine(true, errChan)
+	require.NoError(t, <-errChan)
+	require.NoError(t, transport.Shutdown(context.Background()))
+
+	spans := collector.Spans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, otlp.StatusCodeUnset, spans[0].Status.Code)
+}
+
+func TestTransportOpenJobsCap(t *testing.T) {
+	collector := sysreporttest.NewOTLPCollector()
+	defer collector.Close()
+	dropped := make(chan error, 10)
+	transport := otlp.NewTransport(otlp.Config{Endpoint: collector.URL, HttpClient: collector.Client(), MaxPending: 100,
+		ErrorHandler: func(err error) { dropped <- err }})
+
+	ctx := context.Background()
+	for i := 0; i <= 10000; i++ {
+		report := fmt.Sprintf(`{"reporter":"my-reporter","status":"started","jobID":"job-%d"}`, i)
+		_, err := transport.Deliver(ctx, []byte(report))
+		require.NoError(t, err)
+	}
+	// the idle jobs are forgotten and their spans ended, the export queue keeps the newest of them only
+	assert.ErrorContains(t, <-dropped, "dropped 900 spans")
+	require.NoError(t, transport.Flush(ctx))
+	spans := collector.Spans()
+	require.Len(t, spans, 100)
+	jobID, _ := spans[99].Attribute(otlp.AttrJobID)
+	assert.Equal(t, "job-999", jobID)
+	assert.Equal(t, otlp.StatusCodeUnset, spans[99].Status.Code)
+}
+
+func attribute(attributes []otlp.KeyValue, key string) (string, bool) {
+	for i := range attributes {
+		if attributes[i].Key == key {
+			return attributes[i].Value.StringValue, true
+		}
+	}
+	return "", false
+}
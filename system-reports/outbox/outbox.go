@@ -0,0 +1,378 @@
+// durable on-disk outbox for system reports that could not be delivered
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/armosec/logger-go/system-reports/datastructures"
+	"github.com/armosec/utils-go/httputils"
+)
+
+const (
+	segmentSuffix         = ".jsonl"
+	defaultMaxSegmentSize = 4 << 20  // 4MiB
+	defaultMaxTotalSize   = 64 << 20 // 64MiB
+)
+
+// ErrOutboxFull is returned by Put when keeping the report would exceed the outbox size cap
+var ErrOutboxFull = errors.New("outbox is full")
+
+// DeliverFunc replays a single entry. Returning an error stops the flush, the entry and the ones after it are kept for the next flush
+type DeliverFunc func(ctx context.Context, entry datastructures.OutboxEntry) error
+
+// Config outbox configuration
+type Config struct {
+	Dir            string // directory of the segment files, created if missing
+	MaxSegmentSize int64  // a new segment file is started once the current one reaches this size, default 4MiB
+	MaxTotalSize   int64  // size cap of all segments together, Put fails with ErrOutboxFull above it, default 64MiB
+
+	Logger datastructures.Logger // default the global logger of the system reports
+}
+
+/*
+FileOutbox keeps undelivered reports in append-only JSON-lines segment files.
+
+Entries are replayed in the order they were put. A segment file is removed only after all of its entries were delivered,
+if the process stops in the middle of a flush the entries of the current segment are delivered again (at-least-once).
+*/
+type FileOutbox struct {
+	config    Config
+	mu        sync.Mutex
+	flushMu   sync.Mutex
+	current   *os.File // segment currently written to, nil if no entry was put since the last flush
+	currSize  int64
+	nextSeq   uint64
+	totalSize int64
+}
+
+// NewFileOutbox opens (or creates) the outbox in config.Dir, segments left by a previous run are kept for the next flush
+func NewFileOutbox(config Config) (*FileOutbox, error) {
+	if config.Dir == "" {
+		return nil, fmt.Errorf("outbox directory is not set")
+	}
+	if config.MaxSegmentSize <= 0 {
+		config.MaxSegmentSize = defaultMaxSegmentSize
+	}
+	if config.MaxTotalSize <= 0 {
+		config.MaxTotalSize = defaultMaxTotalSize
+	}
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+	o := &FileOutbox{config: config}
+	if err := o.removeTempFiles(); err != nil {
+		return nil, err
+	}
+	segments, err := o.segments()
+	if err != nil {
+		return nil, err
+	}
+	for _, segment := range segments {
+		o.totalSize += segment.size
+		if segment.seq >= o.nextSeq {
+			o.nextSeq = segment.seq + 1
+		}
+	}
+	return o, nil
+}
+
+// Put appends the entry to the current segment
+func (o *FileOutbox) Put(entry datastructures.OutboxEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.totalSize+int64(len(line)) > o.config.MaxTotalSize {
+		return ErrOutboxFull
+	}
+	if o.current != nil && o.currSize+int64(len(line)) > o.config.MaxSegmentSize {
+		if err := o.unprotectedSeal(); err != nil {
+			return err
+		}
+	}
+	if o.current == nil {
+		name := filepath.Join(o.config.Dir, fmt.Sprintf("%020d%s", o.nextSeq, segmentSuffix))
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to create outbox segment: %w", err)
+		}
+		o.nextSeq++
+		o.current = f
+		o.currSize = 0
+	}
+	if _, err := o.current.Write(line); err != nil {
+		return fmt.Errorf("failed to write outbox segment: %w", err)
+	}
+	if err := o.current.Sync(); err != nil {
+		return fmt.Errorf("failed to sync outbox segment: %w", err)
+	}
+	o.currSize += int64(len(line))
+	o.totalSize += int64(len(line))
+	return nil
+}
+
+// Size returns the total size in bytes of the entries waiting in the outbox
+func (o *FileOutbox) Size() int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.totalSize
+}
+
+// Flush replays the entries in order using deliver. It stops on the first entry deliver fails on, and returns its error
+func (o *FileOutbox) Flush(ctx context.Context, deliver DeliverFunc) error {
+	o.flushMu.Lock()
+	defer o.flushMu.Unlock()
+
+	// segments created by Put after the seal are left for the next flush
+	o.mu.Lock()
+	err := o.unprotectedSeal()
+	sealedSeq := o.nextSeq
+	o.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	segments, err := o.segments()
+	if err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		if segment.seq >= sealedSeq {
+			break
+		}
+		if err := o.flushSegment(ctx, segment, deliver); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run flushes the outbox every interval until ctx is done
+func (o *FileOutbox) Run(ctx context.Context, interval time.Duration, deliver DeliverFunc) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := o.Flush(ctx, deliver); err != nil && ctx.Err() == nil {
+				o.logger().Error("outbox flush stopped", err)
+			}
+		}
+	}
+}
+
+// Close closes the current segment, entries are kept on disk
+func (o *FileOutbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.unprotectedSeal()
+}
+
+// unprotectedSeal closes the current segment so the next Put starts a new one
+func (o *FileOutbox) unprotectedSeal() error {
+	if o.current == nil {
+		return nil
+	}
+	err := o.current.Close()
+	o.current = nil
+	o.currSize = 0
+	return err
+}
+
+func (o *FileOutbox) flushSegment(ctx context.Context, segment segmentInfo, deliver DeliverFunc) error {
+	entries, err := o.readSegment(segment.path)
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		if err := ctx.Err(); err != nil {
+			return o.keepSegmentTail(segment, entries[i:], err)
+		}
+		if err := deliver(ctx, entries[i]); err != nil {
+			return o.keepSegmentTail(segment, entries[i:], err)
+		}
+	}
+	if err := os.Remove(segment.path); err != nil {
+		return fmt.Errorf("failed to remove delivered outbox segment: %w", err)
+	}
+	o.mu.Lock()
+	o.totalSize -= segment.size
+	o.mu.Unlock()
+	return nil
+}
+
+// keepSegmentTail rewrites the segment with the entries that were not delivered yet, and returns deliverErr
+func (o *FileOutbox) keepSegmentTail(segment segmentInfo, tail []datastructures.OutboxEntry, deliverErr error) error {
+	tmp := segment.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("%v (failed to rewrite outbox segment: %v)", deliverErr, err)
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for i := range tail {
+		if err = enc.Encode(tail[i]); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, segment.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("%v (failed to rewrite outbox segment: %v)", deliverErr, err)
+	}
+	if info, err := os.Stat(segment.path); err == nil {
+		o.mu.Lock()
+		o.totalSize -= segment.size - info.Size()
+		o.mu.Unlock()
+	}
+	return deliverErr
+}
+
+type segmentInfo struct {
+	path string
+	seq  uint64
+	size int64
+}
+
+// removeTempFiles removes the segment tails a previous run did not finish writing (see keepSegmentTail), the segment
+// itself was not replaced so its entries are still there
+func (o *FileOutbox) removeTempFiles() error {
+	tmpFiles, err := filepath.Glob(filepath.Join(o.config.Dir, "*"+segmentSuffix+".tmp"))
+	if err != nil {
+		return fmt.Errorf("failed to list outbox temporary files: %w", err)
+	}
+	for _, tmp := range tmpFiles {
+		if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove outbox temporary file: %w", err)
+		}
+	}
+	return nil
+}
+
+// segments returns the segment files sorted by their sequence
+func (o *FileOutbox) segments() ([]segmentInfo, error) {
+	dirEntries, err := os.ReadDir(o.config.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list outbox directory: %w", err)
+	}
+	segments := make([]segmentInfo, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if dirEntry.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat outbox segment: %w", err)
+		}
+		segments = append(segments, segmentInfo{path: filepath.Join(o.config.Dir, name), seq: seq, size: info.Size()})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].seq < segments[j].seq })
+	return segments, nil
+}
+
+// readSegment reads the entries of a segment, a torn last line (crash in the middle of a write) is skipped
+func (o *FileOutbox) readSegment(path string) ([]datastructures.OutboxEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open outbox segment: %w", err)
+	}
+	defer f.Close()
+
+	entries := []datastructures.OutboxEntry{}
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			entry := datastructures.OutboxEntry{}
+			if jsonErr := json.Unmarshal(line, &entry); jsonErr != nil {
+				o.logger().Error("skipping malformed outbox entry", jsonErr, datastructures.F("segment", path))
+			} else {
+				entries = append(entries, entry)
+			}
+		}
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read outbox segment: %w", err)
+		}
+	}
+}
+
+func (o *FileOutbox) logger() datastructures.Logger {
+	if o.config.Logger != nil {
+		return o.config.Logger
+	}
+	return datastructures.NewRedactingLogger(datastructures.GetLogger(), datastructures.GetRedactor())
+}
+
+// HTTPDeliver returns a DeliverFunc posting the report to the url, and with the trace headers, it was originally sent with.
+// Reports rejected with a permanent status (see datastructures.IsRetryableStatus) are dropped.
+// The authenticator (optional) authenticates the requests, as it does for the reporters
+func HTTPDeliver(httpClient httputils.IHttpClient, authenticator ...datastructures.Authenticator) DeliverFunc {
+	auth := datastructures.ChainAuthenticators(authenticator...)
+	return func(ctx context.Context, entry datastructures.OutboxEntry) error {
+		headers := map[string]string{"Content-Type": "application/json"}
+		authHeaders, err := auth.Authenticate(ctx, http.MethodPost, entry.URL, entry.Report)
+		if err != nil {
+			return fmt.Errorf("failed to authenticate report %s: %w", entry.ReportID, err)
+		}
+		for k, v := range authHeaders {
+			headers[k] = v
+		}
+		if entry.TraceParent != "" {
+			headers[datastructures.TraceParentHeader] = entry.TraceParent
+			if entry.TraceState != "" {
+				headers[datastructures.TraceStateHeader] = entry.TraceState
+			}
+		}
+		resp, err := httputils.HttpPostWithContext(ctx, httpClient, entry.URL, headers, entry.Report)
+		if err != nil {
+			return fmt.Errorf("failed to replay report %s: %w", entry.ReportID, err)
+		}
+		body, _ := httputils.HttpRespToString(resp)
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		if !datastructures.IsRetryableStatus(resp.StatusCode) {
+			datastructures.NewRedactingLogger(datastructures.GetLogger(), datastructures.GetRedactor()).Warn("dropping report from the outbox", datastructures.F(datastructures.LogFieldReportID, entry.ReportID),
+				datastructures.F(datastructures.LogFieldURL, entry.URL), datastructures.F(datastructures.LogFieldStatusCode, resp.StatusCode), datastructures.F("body", body))
+			return nil
+		}
+		return fmt.Errorf("failed to replay report %s, status: %d (%s)", entry.ReportID, resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+}
@@ -0,0 +1,168 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/armosec/logger-go/system-reports/datastructures"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func entry(id int) datastructures.OutboxEntry {
+	return datastructures.OutboxEntry{
+		URL:       "http://receiver/k8s/sysreport",
+		ReportID:  fmt.Sprintf("report-%d", id),
+		Report:    json.RawMessage(fmt.Sprintf(`{"actionID":"%d"}`, id)),
+		Timestamp: time.Now(),
+	}
+}
+
+func TestFileOutboxFlushInOrder(t *testing.T) {
+	o, err := NewFileOutbox(Config{Dir: t.TempDir(), MaxSegmentSize: 200})
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, o.Put(entry(i)))
+	}
+
+	// fail on the 5th entry, the first 4 are not kept
+	delivered := []string{}
+	err = o.Flush(context.Background(), func(ctx context.Context, e datastructures.OutboxEntry) error {
+		if e.ReportID == "report-4" {
+			return fmt.Errorf("receiver is down")
+		}
+		delivered = append(delivered, e.ReportID)
+		return nil
+	})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"report-0", "report-1", "report-2", "report-3"}, delivered)
+
+	delivered = []string{}
+	err = o.Flush(context.Background(), func(ctx context.Context, e datastructures.OutboxEntry) error {
+		delivered = append(delivered, e.ReportID)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"report-4", "report-5", "report-6", "report-7", "report-8", "report-9"}, delivered)
+	assert.Equal(t, int64(0), o.Size())
+}
+
+func TestFileOutboxSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	o, err := NewFileOutbox(Config{Dir: dir})
+	require.NoError(t, err)
+	require.NoError(t, o.Put(entry(1)))
+	require.NoError(t, o.Put(entry(2)))
+	require.NoError(t, o.Close())
+	// left by a flush that stopped in the middle of keepSegmentTail
+	tmp := filepath.Join(dir, fmt.Sprintf("%020d%s.tmp", 0, segmentSuffix))
+	require.NoError(t, os.WriteFile(tmp, []byte("partial"), 0644))
+
+	reopened, err := NewFileOutbox(Config{Dir: dir})
+	require.NoError(t, err)
+	assert.NoFileExists(t, tmp)
+	assert.Equal(t, o.Size(), reopened.Size())
+	require.NoError(t, reopened.Put(entry(3)))
+
+	delivered := []string{}
+	err = reopened.Flush(context.Background(), func(ctx context.Context, e datastructures.OutboxEntry) error {
+		delivered = append(delivered, e.ReportID)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"report-1", "report-2", "report-3"}, delivered)
+}
+
+func TestFileOutboxSizeCap(t *testing.T) {
+	o, err := NewFileOutbox(Config{Dir: t.TempDir(), MaxTotalSize: 300})
+	require.NoError(t, err)
+	for i := 0; ; i++ {
+		if err = o.Put(entry(i)); err != nil {
+			break
+		}
+	}
+	assert.ErrorIs(t, err, ErrOutboxFull)
+	assert.LessOrEqual(t, o.Size(), int64(300))
+}
+
+func TestUndeliveredReportIsReplayed(t *testing.T) {
+	defer func(retries int, delay time.Duration) {
+		datastructures.MAX_RETRIES, datastructures.RETRY_DELAY = retries, delay
+	}(datastructures.MAX_RETRIES, datastructures.RETRY_DELAY)
+	datastructures.MAX_RETRIES = 2
+	datastructures.RETRY_DELAY = 0
+
+	mu := sync.Mutex{}
+	down := true
+	received := []string{}
+	traceParents := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, string(body))
+		traceParents = append(traceParents, r.Header.Get(datastructures.TraceParentHeader))
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	o, err := NewFileOutbox(Config{Dir: t.TempDir()})
+	require.NoError(t, err)
+
+	reporter := datastructures.NewBaseReport("a-user-guid", "my-reporter", server.URL, server.Client())
+	reporter.SetOutbox(o)
+	_, _, err = reporter.Send()
+	assert.Error(t, err)
+	assert.Equal(t, int64(0), o.Size(), "the first report of a job is not kept, its jobID would be lost")
+
+	reporter.SetJobID("a-job")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = reporter.SendContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int64(0), o.Size(), "a canceled report is not kept")
+
+	writerReporter := datastructures.NewBaseReport("a-user-guid", "my-reporter", "", nil)
+	writerReporter.SetJobID("a-job")
+	writerReporter.SetOutbox(o)
+	writerReporter.SetTransport(failingTransport{})
+	_, _, err = writerReporter.Send()
+	assert.Error(t, err)
+	assert.Equal(t, int64(0), o.Size(), "a report of another transport is not kept, it can not be replayed over http")
+
+	traceContext := datastructures.TraceContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", TraceFlags: 1}
+	reporter.SetTraceContext(traceContext)
+	_, _, err = reporter.Send()
+	assert.Error(t, err)
+	assert.Greater(t, o.Size(), int64(0))
+
+	mu.Lock()
+	down = false
+	mu.Unlock()
+	assert.NoError(t, o.Flush(context.Background(), HTTPDeliver(server.Client())))
+	assert.Equal(t, int64(0), o.Size())
+	require.Len(t, received, 1)
+	assert.Contains(t, received[0], `"reporter":"my-reporter"`)
+	assert.Contains(t, received[0], `"jobID":"a-job"`)
+	assert.Equal(t, []string{traceContext.TraceParent()}, traceParents)
+}
+
+// failingTransport a transport that is always unavailable
+type failingTransport struct{}
+
+func (failingTransport) Deliver(context.Context, []byte) (datastructures.Result, error) {
+	return datastructures.Result{StatusCode: http.StatusServiceUnavailable}, nil
+}
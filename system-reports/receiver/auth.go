@@ -0,0 +1,50 @@
+package receiver
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/armosec/logger-go/system-reports/datastructures"
+)
+
+// APIKeyVerifier accepts the requests with one of the keys in the header (default X-API-KEY), see datastructures.APIKeyAuthenticator
+func APIKeyVerifier(header string, keys ...string) Verifier {
+	if header == "" {
+		header = datastructures.DefaultAPIKeyHeader
+	}
+	return func(r *http.Request, body []byte) error {
+		key := r.Header.Get(header)
+		if key == "" {
+			return fmt.Errorf("missing %s header", header)
+		}
+		for i := range keys {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(keys[i])) == 1 {
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid api key")
+	}
+}
+
+// BearerTokenVerifier accepts the requests with a bearer token the verify function accepts, eg. a TokenReview of a
+// projected service account token
+func BearerTokenVerifier(verify func(r *http.Request, token string) error) Verifier {
+	return func(r *http.Request, body []byte) error {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			return fmt.Errorf("missing bearer token")
+		}
+		return verify(r, token)
+	}
+}
+
+// HMACVerifier accepts the requests signed with the secret, with a timestamp at most maxSkew off (0 for no limit),
+// see datastructures.HMACAuthenticator
+func HMACVerifier(secret []byte, maxSkew time.Duration) Verifier {
+	return func(r *http.Request, body []byte) error {
+		return datastructures.VerifyHMACSignature(secret, r.Method, r.URL.String(), body, r.Header.Get, maxSkew)
+	}
+}
@@ -0,0 +1,160 @@
+package receiver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/armosec/logger-go/system-reports/datastructures"
+	"github.com/francoispqt/gojay"
+)
+
+// FileStoreConfig FileStore configuration
+type FileStoreConfig struct {
+	Path   string                // path of the append log, created if missing
+	Fsync  bool                  // sync the file after every report, default the OS decides when
+	Logger datastructures.Logger // default the global logger
+}
+
+/*
+FileStore a QueryableStore appending every report as a single JSON line to a local file. The reports are indexed in
+memory (see MemoryStore) and loaded again from the file when it is opened.
+
+A line that was only partially written (eg. the process was killed while writing it) is cut from the end of the file,
+other lines that cannot be decoded are skipped
+*/
+type FileStore struct {
+	*MemoryStore
+	config FileStoreConfig
+	mu     sync.Mutex
+	file   *os.File
+	offset int64 // end of the last complete line, where the next report is written
+}
+
+var _ QueryableStore = (*FileStore)(nil)
+
+// OpenFileStore opens the append log and loads its reports
+func OpenFileStore(config FileStoreConfig) (*FileStore, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("file store path is required")
+	}
+	file, err := os.OpenFile(config.Path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open report store: %w", err)
+	}
+	s := &FileStore{MemoryStore: NewMemoryStore(), config: config, file: file}
+	if err := s.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileStore) logger() datastructures.Logger {
+	if s.config.Logger != nil {
+		return s.config.Logger
+	}
+	return datastructures.NewRedactingLogger(datastructures.GetLogger(), datastructures.GetRedactor())
+}
+
+// load indexes the reports of the file, truncates a partially written last line and leaves the file offset at its end
+func (s *FileStore) load() error {
+	reader := bufio.NewReader(s.file)
+	var offset int64
+	skipped := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				s.logger().Warn("cutting a partially written report from the report store", datastructures.F("path", s.config.Path), datastructures.F("offset", offset))
+				if err := s.file.Truncate(offset); err != nil {
+					return fmt.Errorf("failed to truncate report store: %w", err)
+				}
+			}
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read report store: %w", err)
+		}
+		offset += int64(len(line))
+		if line = bytes.TrimSpace(line); len(line) == 0 {
+			continue
+		}
+		report := &datastructures.BaseReport{}
+		if err := gojay.UnmarshalJSONObject(line, report); err != nil {
+			skipped++
+			continue
+		}
+		s.MemoryStore.add(report)
+	}
+	if skipped > 0 {
+		s.logger().Warn("skipped reports that could not be decoded", datastructures.F("path", s.config.Path), datastructures.F("skipped", skipped))
+	}
+	if _, err := s.file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek report store: %w", err)
+	}
+	s.offset = offset
+	return nil
+}
+
+// Store appends the report to the file, it is indexed once it was written
+func (s *FileStore) Store(ctx context.Context, report *datastructures.BaseReport) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	line, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return fmt.Errorf("report store is closed")
+	}
+	if _, err := s.file.Write(line); err != nil {
+		s.rollback()
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if s.config.Fsync {
+		if err := s.file.Sync(); err != nil {
+			s.rollback()
+			return fmt.Errorf("failed to sync report store: %w", err)
+		}
+	}
+	s.offset += int64(len(line))
+	// the report is in the file, so it is indexed whatever ctx is
+	s.MemoryStore.mu.Lock()
+	s.MemoryStore.add(report)
+	s.MemoryStore.mu.Unlock()
+	return nil
+}
+
+// rollback cuts a partially written report, so the next report starts on a line of its own
+func (s *FileStore) rollback() {
+	if err := s.file.Truncate(s.offset); err != nil {
+		s.logger().Error("failed to cut a partially written report from the report store", err, datastructures.F("path", s.config.Path))
+	}
+	if _, err := s.file.Seek(s.offset, io.SeekStart); err != nil {
+		s.logger().Error("failed to seek report store", err, datastructures.F("path", s.config.Path))
+	}
+}
+
+// Close closes the file, the stored reports can still be queried
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,237 @@
+// event receiver for system reports, the server side of BaseReport.Send
+package receiver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/armosec/logger-go/system-reports/datastructures"
+	"github.com/francoispqt/gojay"
+)
+
+const (
+	DefaultEndpoint     = "/k8s/sysreport"
+	DefaultBulkEndpoint = "/k8s/sysreport/bulk"
+	defaultMaxBodySize  = 1 << 20
+	maxFieldLength      = 1024
+)
+
+// ReportStore keeps the received reports, see MemoryStore and FileStore
+type ReportStore interface {
+	// Store stores a valid report. The first report of a job already has the jobID the handler assigned to it
+	Store(ctx context.Context, report *datastructures.BaseReport) error
+}
+
+// Verifier authenticates a request, see APIKeyVerifier and HMACVerifier
+type Verifier func(r *http.Request, body []byte) error
+
+// Config Handler configuration
+type Config struct {
+	Store        ReportStore            // required
+	Endpoint     string                 // path of the reports, default "/k8s/sysreport"
+	BulkEndpoint string                 // path of the bulk requests of datastructures.BatchingSender, default "/k8s/sysreport/bulk"
+	DisableBulk  bool                   // answer the bulk endpoint with 404, so the clients send every report on its own
+	StrictStatus bool                   // reject reports of an unknown status with 400, by default they are stored as is and logged
+	MaxBodySize  int64                  // maximal request size in bytes, default 1MB
+	Verifier     Verifier               // authenticates the requests, optional
+	NewJobID     func() (string, error) // generates the jobID of new jobs, default datastructures.NewJobID
+	Logger       datastructures.Logger  // default the global logger
+}
+
+/*
+Handler an http.Handler receiving system reports.
+
+A report is decoded, validated and stored, the first report of a job (a report without a jobID) is assigned a new jobID.
+A report of an unknown status (eg. of a newer client) is stored as is, unless Config.StrictStatus is set.
+The answer is the one BaseReport.Send expects - the jobID for the first report of a job and "ok" from the 2nd report
+onwards. Invalid reports are rejected with 400, which the clients do not retry, while store failures answer 500 and
+are retried.
+
+	store, err := receiver.OpenFileStore(receiver.FileStoreConfig{Path: "/var/lib/armo/sysreports.jsonl"})
+	handler, err := receiver.NewHandler(receiver.Config{Store: store})
+	http.ListenAndServe(":8080", handler)
+*/
+type Handler struct {
+	config Config
+}
+
+// NewHandler returns a handler storing the reports in config.Store
+func NewHandler(config Config) (*Handler, error) {
+	if config.Store == nil {
+		return nil, fmt.Errorf("a report store is required")
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = DefaultEndpoint
+	}
+	if config.BulkEndpoint == "" {
+		config.BulkEndpoint = DefaultBulkEndpoint
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = defaultMaxBodySize
+	}
+	if config.NewJobID == nil {
+		config.NewJobID = datastructures.NewJobID
+	}
+	return &Handler{config: config}, nil
+}
+
+func (h *Handler) logger() datastructures.Logger {
+	if h.config.Logger != nil {
+		return h.config.Logger
+	}
+	return datastructures.NewRedactingLogger(datastructures.GetLogger(), datastructures.GetRedactor())
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bulk := false
+	switch r.URL.Path {
+	case h.config.Endpoint:
+	case h.config.BulkEndpoint:
+		if h.config.DisableBulk {
+			http.NotFound(w, r)
+			return
+		}
+		bulk = true
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.config.MaxBodySize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, fmt.Sprintf("request is larger than %d bytes", h.config.MaxBodySize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+	if h.config.Verifier != nil {
+		if err := h.config.Verifier(r, body); err != nil {
+			h.logger().Warn("rejected unauthenticated request", datastructures.F(datastructures.LogFieldURL, r.URL.Path), datastructures.F("error", err.Error()))
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	traceContext, _ := datastructures.ExtractTraceHeaders(r.Header)
+
+	if bulk {
+		h.serveBulk(w, r.Context(), body, traceContext)
+		return
+	}
+	report := &datastructures.BaseReport{}
+	if err := gojay.UnmarshalJSONObject(body, report); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode report: %v", err), http.StatusBadRequest)
+		return
+	}
+	result := h.receive(r.Context(), report, traceContext)
+	if result.Status != http.StatusOK {
+		http.Error(w, result.Body, result.Status)
+		return
+	}
+	w.Write([]byte(result.Body))
+}
+
+// serveBulk answers a bulk request with the result of every report, in the order of the reports in the request
+func (h *Handler) serveBulk(w http.ResponseWriter, ctx context.Context, body []byte, traceContext datastructures.TraceContext) {
+	reports := reportList{}
+	if err := gojay.UnmarshalJSONArray(body, &reports); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode reports: %v", err), http.StatusBadRequest)
+		return
+	}
+	results := make([]datastructures.BulkItemResult, len(reports))
+	for i := range reports {
+		results[i] = h.receive(ctx, reports[i], traceContext)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// receive validates, assigns the jobID of and stores a single report
+func (h *Handler) receive(ctx context.Context, report *datastructures.BaseReport, traceContext datastructures.TraceContext) datastructures.BulkItemResult {
+	if err := ValidateReport(report); err != nil {
+		return datastructures.BulkItemResult{Status: http.StatusBadRequest, Body: err.Error()}
+	}
+	if !report.Status.IsValid() {
+		if h.config.StrictStatus {
+			return datastructures.BulkItemResult{Status: http.StatusBadRequest, Body: fmt.Sprintf("unknown status '%s'", report.Status)}
+		}
+		h.logger().Warn("received a report of an unknown status", datastructures.F(datastructures.LogFieldReportID, report.GetReportID()),
+			datastructures.F("status", string(report.Status)))
+	}
+	if traceContext.IsValid() {
+		report.SetTraceContext(traceContext)
+	}
+	answer := "ok"
+	if report.JobID == "" {
+		jobID, err := h.config.NewJobID()
+		if err != nil {
+			h.logger().Error("failed to assign jobID", err, datastructures.F(datastructures.LogFieldReportID, report.GetReportID()))
+			return datastructures.BulkItemResult{Status: http.StatusInternalServerError, Body: "failed to assign jobID"}
+		}
+		report.JobID = jobID
+		answer = jobID
+	}
+	if err := h.config.Store.Store(ctx, report); err != nil {
+		h.logger().Error("failed to store report", err, datastructures.F(datastructures.LogFieldReportID, report.GetReportID()),
+			datastructures.F(datastructures.LogFieldJobID, report.JobID))
+		return datastructures.BulkItemResult{Status: http.StatusInternalServerError, Body: "failed to store report"}
+	}
+	return datastructures.BulkItemResult{Status: http.StatusOK, Body: answer}
+}
+
+/*
+ValidateReport returns an error if a report misses a required field - customerGUID, reporter, status and actionID - or
+if a field is malformed. The status may be unknown, see Config.StrictStatus
+*/
+func ValidateReport(report *datastructures.BaseReport) error {
+	missing := []string{}
+	if report.CustomerGUID == "" {
+		missing = append(missing, "customerGUID")
+	}
+	if report.Reporter == "" {
+		missing = append(missing, "reporter")
+	}
+	if report.Status == "" {
+		missing = append(missing, "status")
+	}
+	if report.ActionID == "" {
+		missing = append(missing, "actionID")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required fields: %s", strings.Join(missing, ", "))
+	}
+	if report.ActionIDN < 0 {
+		return fmt.Errorf("invalid numSeq %d", report.ActionIDN)
+	}
+	for _, field := range []struct{ name, value string }{{"customerGUID", report.CustomerGUID}, {"reporter", report.Reporter},
+		{"jobID", report.JobID}, {"parentAction", report.ParentAction}, {"actionID", report.ActionID}} {
+		if len(field.value) > maxFieldLength {
+			return fmt.Errorf("%s is longer than %d characters", field.name, maxFieldLength)
+		}
+	}
+	return nil
+}
+
+// reportList decodes the JSON array of a bulk request
+type reportList []*datastructures.BaseReport
+
+func (list *reportList) UnmarshalJSONArray(dec *gojay.Decoder) error {
+	report := &datastructures.BaseReport{}
+	if err := dec.Object(report); err != nil {
+		return err
+	}
+	*list = append(*list, report)
+	return nil
+}
@@ -0,0 +1,274 @@
+package receiver_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/armosec/logger-go/system-reports/datastructures"
+	"github.com/armosec/logger-go/system-reports/receiver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testStore struct {
+	mu      sync.Mutex
+	reports []*datastructures.BaseReport
+	fail    int
+}
+
+func (s *testStore) Store(ctx context.Context, report *datastructures.BaseReport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail > 0 {
+		s.fail--
+		return fmt.Errorf("store is down")
+	}
+	s.reports = append(s.reports, report)
+	return nil
+}
+
+func (s *testStore) FailNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fail = n
+}
+
+func (s *testStore) Reports() []*datastructures.BaseReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*datastructures.BaseReport{}, s.reports...)
+}
+
+func newServer(t *testing.T, config receiver.Config) (*httptest.Server, *testStore) {
+	store := &testStore{}
+	config.Store = store
+	jobs := 0
+	var mu sync.Mutex
+	config.NewJobID = func() (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		jobs++
+		return fmt.Sprintf("job-%d", jobs), nil
+	}
+	handler, err := receiver.NewHandler(config)
+	require.NoError(t, err)
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server, store
+}
+
+func TestHandler(t *testing.T) {
+	server, store := newServer(t, receiver.Config{})
+	reporter := datastructures.NewBaseReport("a-user-guid", "my-reporter", server.URL, server.Client())
+	reporter.SetRetryPolicy(&datastructures.ConstantRetryPolicy{MaxAttempts: 2, Delay: time.Millisecond})
+	reporter.SetLabel("cluster", "my-cluster")
+
+	status, body, err := reporter.Send()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "job-1", body)
+	assert.Equal(t, "job-1", reporter.GetJobID())
+
+	errChan := make(chan error)
+	reporter.SendStatus(datastructures.JobSuccess, true, errChan)
+	require.NoError(t, <-errChan)
+
+	child := datastructures.NewBaseReport("a-user-guid", "my-reporter", server.URL, server.Client())
+	child.SetParentAction(reporter.GetJobID())
+	_, body, err = child.Send()
+	require.NoError(t, err)
+	assert.Equal(t, "job-2", body)
+
+	reports := store.Reports()
+	require.Len(t, reports, 3)
+	assert.Equal(t, "job-1", reports[0].JobID)
+	assert.Equal(t, "my-cluster", reports[0].Labels["cluster"])
+	assert.Equal(t, "job-1", reports[1].JobID)
+	assert.Equal(t, datastructures.JobSuccess, reports[1].Status)
+	assert.Equal(t, "job-2", reports[2].JobID)
+	assert.Equal(t, "job-1", reports[2].ParentAction)
+
+	t.Run("store failures are retried", func(t *testing.T) {
+		store.FailNext(1)
+		reporter.SetDetails("retried")
+		_, body, err := reporter.Send()
+		require.NoError(t, err)
+		assert.Equal(t, "ok", body)
+		assert.Equal(t, "retried", store.Reports()[3].Details)
+	})
+
+	t.Run("invalid reports are rejected", func(t *testing.T) {
+		invalid := datastructures.NewBaseReport("", "my-reporter", server.URL, server.Client())
+		invalid.SetRetryPolicy(&datastructures.ConstantRetryPolicy{MaxAttempts: 3, Delay: time.Millisecond})
+		_, body, err := invalid.Send()
+		assert.ErrorContains(t, err, "unexpected status code 400", "rejected on the 1st attempt")
+		assert.Contains(t, body, "missing required fields: customerGUID")
+
+		resp, err := server.Client().Post(server.URL+receiver.DefaultEndpoint, "application/json", strings.NewReader(`{"customerGUID":`))
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Len(t, store.Reports(), 4)
+	})
+
+	t.Run("unknown status", func(t *testing.T) {
+		unknown := `{"customerGUID":"a","reporter":"r","status":"paused","actionID":"1"}`
+		resp, err := server.Client().Post(server.URL+receiver.DefaultEndpoint, "application/json", strings.NewReader(unknown))
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "stored as is")
+		reports := store.Reports()
+		require.Len(t, reports, 5)
+		assert.Equal(t, datastructures.StatusType("paused"), reports[4].Status)
+
+		strict, strictStore := newServer(t, receiver.Config{StrictStatus: true})
+		resp, err = strict.Client().Post(strict.URL+receiver.DefaultEndpoint, "application/json", strings.NewReader(unknown))
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Empty(t, strictStore.Reports())
+	})
+
+	t.Run("requests", func(t *testing.T) {
+		resp, err := server.Client().Get(server.URL + receiver.DefaultEndpoint)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+		resp, err = server.Client().Post(server.URL+"/unknown", "application/json", strings.NewReader("{}"))
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestHandlerMaxBodySize(t *testing.T) {
+	server, store := newServer(t, receiver.Config{MaxBodySize: 256})
+	reporter := datastructures.NewBaseReport("a-user-guid", "my-reporter", server.URL, server.Client())
+	reporter.SetRetryPolicy(&datastructures.ConstantRetryPolicy{MaxAttempts: 1})
+	reporter.SetDetails(strings.Repeat("a", 512))
+	_, _, err := reporter.Send()
+	assert.ErrorContains(t, err, "unexpected status code 413")
+	assert.Empty(t, store.Reports())
+}
+
+func TestHandlerBulk(t *testing.T) {
+	server, store := newServer(t, receiver.Config{})
+	sender := datastructures.NewBatchingSender(datastructures.BatchingConfig{EventReceiverUrl: server.URL, HttpClient: server.Client(), MaxBatchSize: 3, FlushInterval: time.Minute})
+	defer sender.Close()
+
+	errChans := []chan error{}
+	reporters := []*datastructures.BaseReport{}
+	for i := 0; i < 3; i++ {
+		customerGUID := "a-user-guid"
+		if i == 1 {
+			customerGUID = ""
+		}
+		reporter := datastructures.NewBaseReport(customerGUID, "my-reporter", server.URL, server.Client())
+		reporter.SetTarget(fmt.Sprintf("wl%d", i))
+		reporter.SetBatchingSender(sender)
+		errChan := make(chan error, 1)
+		reporter.SendAsRoutine(false, errChan)
+		errChans = append(errChans, errChan)
+		reporters = append(reporters, reporter)
+	}
+	for i, errChan := range errChans {
+		if i == 1 {
+			assert.Error(t, <-errChan, "the invalid report is rejected on its own")
+		} else {
+			assert.NoError(t, <-errChan)
+		}
+	}
+	assert.NotEmpty(t, reporters[0].GetJobID())
+	assert.Empty(t, reporters[1].GetJobID())
+	assert.NotEmpty(t, reporters[2].GetJobID())
+	assert.Len(t, store.Reports(), 2)
+
+	t.Run("disabled", func(t *testing.T) {
+		server, store := newServer(t, receiver.Config{DisableBulk: true})
+		sender := datastructures.NewBatchingSender(datastructures.BatchingConfig{EventReceiverUrl: server.URL, HttpClient: server.Client(), MaxBatchSize: 1})
+		defer sender.Close()
+		reporter := datastructures.NewBaseReport("a-user-guid", "my-reporter", server.URL, server.Client())
+		reporter.SetBatchingSender(sender)
+		_, body, err := reporter.Send()
+		require.NoError(t, err, "the report falls back to the single report endpoint")
+		assert.Equal(t, "job-1", body)
+		assert.Len(t, store.Reports(), 1)
+	})
+}
+
+func TestHandlerVerifier(t *testing.T) {
+	secret := []byte("a-shared-secret")
+	for name, tc := range map[string]struct {
+		verifier      receiver.Verifier
+		authenticator datastructures.Authenticator
+		wrong         datastructures.Authenticator
+	}{
+		"api key": {
+			verifier:      receiver.APIKeyVerifier("", "key-1", "key-2"),
+			authenticator: &datastructures.APIKeyAuthenticator{Key: "key-2"},
+			wrong:         &datastructures.APIKeyAuthenticator{Key: "key-3"},
+		},
+		"hmac": {
+			verifier:      receiver.HMACVerifier(secret, time.Minute),
+			authenticator: &datastructures.HMACAuthenticator{Secret: secret},
+			wrong:         &datastructures.HMACAuthenticator{Secret: []byte("another-secret")},
+		},
+		"bearer token": {
+			verifier: receiver.BearerTokenVerifier(func(r *http.Request, token string) error {
+				if token != "a-token" {
+					return fmt.Errorf("invalid token")
+				}
+				return nil
+			}),
+			authenticator: bearerToken("a-token"),
+			wrong:         bearerToken("another-token"),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			server, store := newServer(t, receiver.Config{Verifier: tc.verifier})
+			reporter := datastructures.NewBaseReport("a-user-guid", "my-reporter", server.URL, server.Client())
+			reporter.SetRetryPolicy(&datastructures.ConstantRetryPolicy{MaxAttempts: 1})
+
+			_, _, err := reporter.Send()
+			assert.ErrorContains(t, err, "unexpected status code 401")
+
+			reporter.SetAuthenticator(tc.wrong)
+			_, _, err = reporter.Send()
+			assert.ErrorContains(t, err, "unexpected status code 401")
+
+			reporter.SetAuthenticator(tc.authenticator)
+			_, _, err = reporter.Send()
+			assert.NoError(t, err)
+			assert.Len(t, store.Reports(), 1)
+		})
+	}
+}
+
+type bearerToken string
+
+func (token bearerToken) Authenticate(ctx context.Context, method, requestUrl string, body []byte) (map[string]string, error) {
+	return map[string]string{"Authorization": "Bearer " + string(token)}, nil
+}
+
+func TestHandlerTraceContext(t *testing.T) {
+	server, store := newServer(t, receiver.Config{})
+	tc, err := datastructures.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+	reporter := datastructures.NewBaseReport("a-user-guid", "my-reporter", server.URL, server.Client())
+	reporter.SetTraceContext(tc)
+	_, _, err = reporter.Send()
+	require.NoError(t, err)
+	assert.Equal(t, tc, store.Reports()[0].GetTraceContext())
+}
+
+func TestNewHandler(t *testing.T) {
+	_, err := receiver.NewHandler(receiver.Config{})
+	assert.Error(t, err, "a store is required")
+}
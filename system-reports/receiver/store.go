@@ -0,0 +1,190 @@
+package receiver
+
+import (
+	"context"
+	"sync"
+
+	"github.com/armosec/logger-go/system-reports/datastructures"
+)
+
+// Query selects the reports matching all the set fields, an empty Query selects every report
+type Query struct {
+	CustomerGUID string
+	JobID        string
+	ParentAction string
+	Target       string
+	Reporter     string
+}
+
+/*
+QueryableStore a ReportStore answering job queries, see MemoryStore and FileStore.
+
+The returned reports are the stored ones, they must not be modified
+*/
+type QueryableStore interface {
+	ReportStore
+	// Find returns the reports matching the query, in the order they were stored
+	Find(query Query) []*datastructures.BaseReport
+	// Jobs returns the jobIDs of the reports matching the query, in the order the jobs were first stored
+	Jobs(query Query) []string
+	// Timeline returns the reports of the job sorted by ActionIDN, then by Timestamp
+	Timeline(jobID string) []*datastructures.BaseReport
+	// ChildJobs returns the jobIDs of the jobs whose parent is the job, in the order they were first stored
+	ChildJobs(jobID string) []string
+	// FinalStatus returns the status of the last report of the job timeline, false if the job is unknown
+	FinalStatus(jobID string) (datastructures.StatusType, bool)
+}
+
+// MemoryStore a QueryableStore keeping the reports in memory, indexed by CustomerGUID, JobID, ParentAction, Target and Reporter
+type MemoryStore struct {
+	mu      sync.RWMutex
+	reports []*datastructures.BaseReport
+	indexes map[string]map[string][]int // index name -> value -> positions in reports
+}
+
+var _ QueryableStore = (*MemoryStore)(nil)
+
+// index names
+const (
+	indexCustomerGUID = "customerGUID"
+	indexJobID        = "jobID"
+	indexParentAction = "parentAction"
+	indexTarget       = "target"
+	indexReporter     = "reporter"
+)
+
+// NewMemoryStore returns an empty store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{indexes: map[string]map[string][]int{
+		indexCustomerGUID: {},
+		indexJobID:        {},
+		indexParentAction: {},
+		indexTarget:       {},
+		indexReporter:     {},
+	}}
+}
+
+func (s *MemoryStore) Store(ctx context.Context, report *datastructures.BaseReport) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.add(report)
+	return nil
+}
+
+func (s *MemoryStore) add(report *datastructures.BaseReport) {
+	position := len(s.reports)
+	s.reports = append(s.reports, report)
+	for name, value := range indexValues(report) {
+		if value != "" {
+			s.indexes[name][value] = append(s.indexes[name][value], position)
+		}
+	}
+}
+
+func indexValues(report *datastructures.BaseReport) map[string]string {
+	return map[string]string{
+		indexCustomerGUID: report.CustomerGUID,
+		indexJobID:        report.JobID,
+		indexParentAction: report.ParentAction,
+		indexTarget:       report.Target,
+		indexReporter:     report.Reporter,
+	}
+}
+
+// Len returns the number of stored reports
+func (s *MemoryStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.reports)
+}
+
+func (s *MemoryStore) Find(query Query) []*datastructures.BaseReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.find(query)
+}
+
+// find scans the shortest index of the set fields and filters it by the other fields
+func (s *MemoryStore) find(query Query) []*datastructures.BaseReport {
+	filters := map[string]string{}
+	for name, value := range map[string]string{
+		indexCustomerGUID: query.CustomerGUID,
+		indexJobID:        query.JobID,
+		indexParentAction: query.ParentAction,
+		indexTarget:       query.Target,
+		indexReporter:     query.Reporter,
+	} {
+		if value != "" {
+			filters[name] = value
+		}
+	}
+	if len(filters) == 0 {
+		return append([]*datastructures.BaseReport{}, s.reports...)
+	}
+	var positions []int
+	first := true
+	for name, value := range filters {
+		if candidates := s.indexes[name][value]; first || len(candidates) < len(positions) {
+			positions, first = candidates, false
+		}
+	}
+	reports := []*datastructures.BaseReport{}
+	for _, position := range positions {
+		report := s.reports[position]
+		values := indexValues(report)
+		matches := true
+		for name, value := range filters {
+			if values[name] != value {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			reports = append(reports, report)
+		}
+	}
+	return reports
+}
+
+func (s *MemoryStore) Jobs(query Query) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return distinctJobs(s.find(query))
+}
+
+func (s *MemoryStore) Timeline(jobID string) []*datastructures.BaseReport {
+	s.mu.RLock()
+	reports := s.find(Query{JobID: jobID})
+	s.mu.RUnlock()
+	datastructures.SortTimeline(reports)
+	return reports
+}
+
+func (s *MemoryStore) ChildJobs(jobID string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return distinctJobs(s.find(Query{ParentAction: jobID}))
+}
+
+func (s *MemoryStore) FinalStatus(jobID string) (datastructures.StatusType, bool) {
+	timeline := s.Timeline(jobID)
+	if len(timeline) == 0 {
+		return "", false
+	}
+	return timeline[len(timeline)-1].Status, true
+}
+
+func distinctJobs(reports []*datastructures.BaseReport) []string {
+	seen := map[string]bool{}
+	jobs := []string{}
+	for _, report := range reports {
+		if report.JobID != "" && !seen[report.JobID] {
+			seen[report.JobID] = true
+			jobs = append(jobs, report.JobID)
+		}
+	}
+	return jobs
+}
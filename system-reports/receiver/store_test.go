@@ -0,0 +1,155 @@
+package receiver_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/armosec/logger-go/system-reports/datastructures"
+	"github.com/armosec/logger-go/system-reports/receiver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newReport(customerGUID, reporter, target, jobID, parentAction string, actionIDN int, status datastructures.StatusType, timestamp time.Time) *datastructures.BaseReport {
+	report := datastructures.NewBaseReport(customerGUID, reporter, "", nil)
+	report.SetTarget(target)
+	report.SetJobID(jobID)
+	report.SetParentAction(parentAction)
+	report.SetActionIDN(actionIDN)
+	report.SetStatus(status)
+	report.SetTimestamp(timestamp)
+	return report
+}
+
+func jobIDs(reports []*datastructures.BaseReport) []string {
+	ids := []string{}
+	for _, report := range reports {
+		ids = append(ids, report.JobID+"#"+report.ActionID)
+	}
+	return ids
+}
+
+// storeReports stores the reports of an autoattach job with 2 attach jobs, in the order they could arrive
+func storeReports(t *testing.T, store receiver.ReportStore) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	reports := []*datastructures.BaseReport{
+		newReport("customer-1", "autoattach", "cluster", "autoattach-job", "", 1, datastructures.JobStarted, now),
+		newReport("customer-1", "attach", "wlid://cluster/ns/deployment-a", "attach-a", "autoattach-job", 1, datastructures.JobStarted, now.Add(time.Second)),
+		// delivered late, after the next step of the job
+		newReport("customer-1", "attach", "wlid://cluster/ns/deployment-a", "attach-a", "autoattach-job", 3, datastructures.JobSuccess, now.Add(3*time.Second)),
+		newReport("customer-1", "attach", "wlid://cluster/ns/deployment-a", "attach-a", "autoattach-job", 2, datastructures.JobStarted, now.Add(2*time.Second)),
+		// same step, ordered by timestamp
+		newReport("customer-1", "attach", "wlid://cluster/ns/deployment-b", "attach-b", "autoattach-job", 2, datastructures.JobFailed, now.Add(5*time.Second)),
+		newReport("customer-1", "attach", "wlid://cluster/ns/deployment-b", "attach-b", "autoattach-job", 2, datastructures.JobStarted, now.Add(4*time.Second)),
+		newReport("customer-1", "autoattach", "cluster", "autoattach-job", "", 2, datastructures.JobDone, now.Add(6*time.Second)),
+		newReport("customer-2", "attach", "wlid://cluster/ns/deployment-a", "attach-c", "", 1, datastructures.JobStarted, now),
+	}
+	for _, report := range reports {
+		require.NoError(t, store.Store(context.Background(), report))
+	}
+}
+
+func testQueries(t *testing.T, store receiver.QueryableStore) {
+	assert.Len(t, store.Find(receiver.Query{}), 8)
+	assert.Equal(t, []string{"attach-a", "attach-c"}, store.Jobs(receiver.Query{Target: "wlid://cluster/ns/deployment-a"}))
+	assert.Equal(t, []string{"attach-a"}, store.Jobs(receiver.Query{CustomerGUID: "customer-1", Target: "wlid://cluster/ns/deployment-a", Reporter: "attach"}))
+	assert.Equal(t, []string{"autoattach-job", "attach-a", "attach-b"}, store.Jobs(receiver.Query{CustomerGUID: "customer-1"}))
+	assert.Len(t, store.Find(receiver.Query{Reporter: "autoattach"}), 2)
+	assert.Empty(t, store.Find(receiver.Query{CustomerGUID: "customer-2", Reporter: "autoattach"}))
+	assert.Empty(t, store.Find(receiver.Query{JobID: "unknown"}))
+
+	assert.Equal(t, []string{"attach-a#1", "attach-a#2", "attach-a#3"}, jobIDs(store.Timeline("attach-a")))
+	timeline := store.Timeline("attach-b")
+	require.Len(t, timeline, 2)
+	assert.Equal(t, datastructures.JobStarted, timeline[0].Status, "reports of the same step are sorted by timestamp")
+	assert.Empty(t, store.Timeline("unknown"))
+
+	assert.Equal(t, []string{"attach-a", "attach-b"}, store.ChildJobs("autoattach-job"))
+	assert.Empty(t, store.ChildJobs("attach-a"))
+
+	for jobID, expected := range map[string]datastructures.StatusType{
+		"autoattach-job": datastructures.JobDone,
+		"attach-a":       datastructures.JobSuccess,
+		"attach-b":       datastructures.JobFailed,
+	} {
+		status, ok := store.FinalStatus(jobID)
+		assert.True(t, ok)
+		assert.Equal(t, expected, status, jobID)
+	}
+	_, ok := store.FinalStatus("unknown")
+	assert.False(t, ok)
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := receiver.NewMemoryStore()
+	storeReports(t, store)
+	assert.Equal(t, 8, store.Len())
+	testQueries(t, store)
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sysreports.jsonl")
+	store, err := receiver.OpenFileStore(receiver.FileStoreConfig{Path: path, Fsync: true})
+	require.NoError(t, err)
+	storeReports(t, store)
+	testQueries(t, store)
+	require.NoError(t, store.Close())
+	assert.Error(t, store.Store(context.Background(), newReport("customer-1", "attach", "", "attach-d", "", 1, datastructures.JobStarted, time.Now())))
+
+	// the reports are loaded again
+	store, err = receiver.OpenFileStore(receiver.FileStoreConfig{Path: path})
+	require.NoError(t, err)
+	testQueries(t, store)
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 1, 0, time.UTC), store.Timeline("attach-a")[0].Timestamp.UTC())
+	require.NoError(t, store.Close())
+
+	// a corrupted line is skipped and a partially written line is cut
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = file.WriteString("not a report\n{\"customerGUID\":\"customer-1\",\"jobID\":\"attach-d")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	store, err = receiver.OpenFileStore(receiver.FileStoreConfig{Path: path})
+	require.NoError(t, err)
+	defer store.Close()
+	assert.Len(t, store.Find(receiver.Query{}), 8)
+	require.NoError(t, store.Store(context.Background(), newReport("customer-1", "attach", "", "attach-e", "", 1, datastructures.JobStarted, time.Now())))
+	require.NoError(t, store.Close())
+
+	store, err = receiver.OpenFileStore(receiver.FileStoreConfig{Path: path})
+	require.NoError(t, err)
+	assert.Len(t, store.Find(receiver.Query{}), 9)
+	assert.Len(t, store.Timeline("attach-e"), 1, "the report stored after the cut line is intact")
+
+	_, err = receiver.OpenFileStore(receiver.FileStoreConfig{})
+	assert.Error(t, err)
+}
+
+func TestHandlerWithStore(t *testing.T) {
+	store := receiver.NewMemoryStore()
+	handler, err := receiver.NewHandler(receiver.Config{Store: store})
+	require.NoError(t, err)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	reporter := datastructures.NewBaseReport("a-user-guid", "attach", server.URL, server.Client())
+	reporter.SetTarget("wlid://cluster/ns/deployment-a")
+	errChan := make(chan error)
+	reporter.SendAsRoutine(true, errChan)
+	require.NoError(t, <-errChan)
+	reporter.SendAction("attaching", true, errChan)
+	require.NoError(t, <-errChan)
+	reporter.SendStatus(datastructures.JobSuccess, true, errChan)
+	require.NoError(t, <-errChan)
+
+	jobs := store.Jobs(receiver.Query{Target: "wlid://cluster/ns/deployment-a", Reporter: "attach"})
+	require.Equal(t, []string{reporter.GetJobID()}, jobs)
+	assert.Len(t, store.Timeline(jobs[0]), 3)
+	status, _ := store.FinalStatus(jobs[0])
+	assert.Equal(t, datastructures.JobSuccess, status)
+}
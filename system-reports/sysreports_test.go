@@ -1,10 +1,25 @@
 package systemReport
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strconv"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/armosec/logger-go/system-reports/datastructures"
 	"github.com/armosec/logger-go/system-reports/utilities"
@@ -51,3 +66,216 @@ func TestBaseReportTestConcurrentErrorAdding(t *testing.T) {
 
 	}
 }
+
+func TestProcessAnnotationsBranch(t *testing.T) {
+	for actionID, want := range map[string]int{"3": 3, "3.2": 3} {
+		reporter := datastructures.NewBaseReport("a-user-guid", "my-reporter", "", nil)
+		annotations := fmt.Sprintf(`{"jobID":"test-job","parentJobID":"","actionID":"%s"}`, actionID)
+		if err := utilities.ProcessAnnotations(reporter, annotations, true); err != nil {
+			t.Errorf("unable to process annotations: %v", err)
+		}
+		if reporter.GetActionID() != actionID || reporter.GetActionIDN() != want || reporter.GetJobID() != "test-job" {
+			t.Errorf("wrong action after processing annotations with actionID %s: %s (%d)", actionID, reporter.GetActionID(), reporter.GetActionIDN())
+		}
+	}
+}
+
+func TestObjectJobsAnnotations(t *testing.T) {
+	report := datastructures.NewBaseReport("a-user-guid", "my-reporter", "", nil)
+	report.SetJobID("test-job")
+	report.SetActionIDN(2)
+
+	annotations, err := datastructures.SetJobsAnnotations(nil, report.NewJobsAnnotations(true, true))
+	if err != nil {
+		t.Fatalf("unable to set job annotations: %v", err)
+	}
+	jobs, found, err := datastructures.GetJobsAnnotations(annotations)
+	if err != nil || !found {
+		t.Fatalf("unable to get job annotations: %v", err)
+	}
+	if jobs.CurrJobID != "test-job" || jobs.ParentJobID != "test-job" || jobs.LastActionID != "2" || jobs.Version != datastructures.JobsAnnotationsVersion {
+		t.Errorf("unexpected job annotations %+v", jobs)
+	}
+
+	reporter := datastructures.NewBaseReport("a-user-guid", "my-reporter", "", nil)
+	if err := utilities.ProcessObjectAnnotations(reporter, annotations, "attach"); err != nil {
+		t.Fatalf("unable to process job annotations: %v", err)
+	}
+	if reporter.GetJobID() != "test-job" || reporter.GetParentAction() != "test-job" || reporter.GetActionIDN() != 2 {
+		t.Errorf("unexpected reporter %v", reporter)
+	}
+
+	if _, err := datastructures.SetJobsAnnotations(nil, datastructures.JobsAnnotations{CurrJobID: "a job", LastActionID: "1"}); err == nil {
+		t.Error("expected an invalid jobID to be rejected")
+	}
+	if _, err := datastructures.SetJobsAnnotations(nil, datastructures.JobsAnnotations{CurrJobID: "test-job", LastActionID: "x"}); err == nil {
+		t.Error("expected an invalid actionID to be rejected")
+	}
+	if err := utilities.ProcessObjectAnnotations(reporter, map[string]string{}, "attach"); err == nil {
+		t.Error("expected missing job annotations to fail")
+	}
+}
+
+func TestParseJobsAnnotations(t *testing.T) {
+	tests := []struct {
+		value   string
+		jobID   string
+		wantErr bool
+	}{
+		{value: `{"jobID":"test-job","parentJobID":"","actionID":"1"}`, jobID: "test-job"},           // written before versioning
+		{value: `{"jobID":"test-job","actionID":"1","version":1,"extra":true}`, jobID: "test-job"},   // unknown fields
+		{value: `"{\"jobID\":\"test-job\",\"actionID\":\"1\"}"`, jobID: "test-job"},                  // double encoded
+		{value: `{"jobID":"test-job","actionID":1}`, jobID: "test-job", wantErr: true},               // malformed field
+		{value: `{"jobID":"test-job","actionID":"1","version":2}`, jobID: "test-job", wantErr: true}, // newer schema
+		{value: `not json`, wantErr: true},
+	}
+	for _, test := range tests {
+		jobs, err := datastructures.ParseJobsAnnotations(test.value)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: unexpected error %v", test.value, err)
+		}
+		if jobs.CurrJobID != test.jobID {
+			t.Errorf("%s: expected jobID '%s', got '%s'", test.value, test.jobID, jobs.CurrJobID)
+		}
+	}
+	if _, err := datastructures.ParseJobsAnnotations(`{"version":2}`); !errors.Is(err, datastructures.ErrUnsupportedJobsAnnotationsVersion) {
+		t.Errorf("expected ErrUnsupportedJobsAnnotationsVersion, got %v", err)
+	}
+}
+
+func TestGetJobIDByContext(t *testing.T) {
+	jobs := datastructures.JobsAnnotations{CurrJobID: "autoattach-job", LastActionID: "3"}
+	jobs.SetContext("attach", datastructures.JobContext{JobID: "attach-job", ParentJobID: "autoattach-job", ActionID: "2"})
+	jobs.SetContext("scan/namespace", datastructures.JobContext{JobID: "scan-ns-job", ActionID: "1.2"})
+	marshal, err := json.Marshal(jobs)
+	if err != nil {
+		t.Fatalf("unable to stringify job annotation: %v", err)
+	}
+
+	for context, want := range map[string]string{
+		"attach":              "attach-job",
+		"attach/workload":     "attach-job",
+		"scan/namespace":      "scan-ns-job",
+		"scan/namespace/pod":  "scan-ns-job",
+		"scan":                "autoattach-job",
+		"":                    "autoattach-job",
+		"unknown-context-job": "autoattach-job",
+	} {
+		jobID, obj, err := utilities.GetJobIDByContext(marshal, context)
+		if err != nil {
+			t.Fatalf("unable to parse json job annotation: %v", err)
+		}
+		if jobID != want || obj.CurrJobID != want {
+			t.Errorf("context '%s': expected jobID '%s', got '%s'", context, want, jobID)
+		}
+	}
+
+	// annotations written before contexts serve every context
+	legacy := `{"jobID":"test-job","parentJobID":"","actionID":"4"}`
+	reporter := datastructures.NewBaseReport("a-user-guid", "my-reporter", "", nil)
+	if err := utilities.ProcessAnnotations(reporter, legacy, true); err != nil {
+		t.Fatalf("unable to process job annotations: %v", err)
+	}
+	if reporter.GetJobID() != "test-job" || reporter.GetActionIDN() != 4 {
+		t.Errorf("unexpected reporter %v", reporter)
+	}
+
+	reporter = datastructures.NewBaseReport("a-user-guid", "my-reporter", "", nil)
+	if err := utilities.ProcessAnnotations(reporter, string(marshal), true); err != nil {
+		t.Fatalf("unable to process job annotations: %v", err)
+	}
+	if reporter.GetJobID() != "attach-job" || reporter.GetParentAction() != "autoattach-job" || reporter.GetActionIDN() != 2 {
+		t.Errorf("unexpected reporter %v", reporter)
+	}
+
+	jobs.SetContext("scan/", datastructures.JobContext{JobID: "scan-job", ActionID: "1"})
+	if _, err := datastructures.SetJobsAnnotations(nil, jobs); err == nil {
+		t.Error("expected an invalid context name to be rejected")
+	}
+}
+
+// writeClientCert writes a self signed client certificate and its key to dir
+func writeClientCert(t *testing.T, dir, name string) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return cert, certFile, keyFile
+}
+
+func TestTLSHttpClient(t *testing.T) {
+	dir := t.TempDir()
+	clientCert, certFile, keyFile := writeClientCert(t, dir, "my-reporter")
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	clients := make(chan string, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clients <- r.TLS.PeerCertificates[0].Subject.CommonName
+		w.Write([]byte("ok"))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "ca.crt")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := utilities.NewTLSHttpClient(utilities.TLSConfig{CertFile: certFile, KeyFile: keyFile, CAFile: caFile})
+	if err != nil {
+		t.Fatalf("unable to create the http client: %v", err)
+	}
+	reporter := datastructures.NewBaseReport("a-user-guid", "my-reporter", server.URL, client)
+	reporter.SetRetryPolicy(&datastructures.ConstantRetryPolicy{MaxAttempts: 1})
+	if _, _, err := reporter.Send(); err != nil {
+		t.Fatalf("failed sending with a client certificate: %v", err)
+	}
+	if cn := <-clients; cn != "my-reporter" {
+		t.Errorf("unexpected client certificate '%s'", cn)
+	}
+
+	noCert, err := utilities.NewTLSHttpClient(utilities.TLSConfig{CAFile: caFile})
+	if err != nil {
+		t.Fatalf("unable to create the http client: %v", err)
+	}
+	reporter = datastructures.NewBaseReport("a-user-guid", "my-reporter", server.URL, noCert)
+	reporter.SetRetryPolicy(&datastructures.ConstantRetryPolicy{MaxAttempts: 1})
+	if _, _, err := reporter.Send(); err == nil {
+		t.Error("expected the server to reject a client without a certificate")
+	}
+
+	if _, err := utilities.NewTLSHttpClient(utilities.TLSConfig{CertFile: certFile}); err == nil {
+		t.Error("expected a missing key file to be rejected")
+	}
+	if _, err := utilities.NewTLSHttpClient(utilities.TLSConfig{CAFile: keyFile}); err == nil {
+		t.Error("expected a CA file without certificates to be rejected")
+	}
+}
@@ -0,0 +1,117 @@
+package sysreporttest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/armosec/logger-go/system-reports/otlp"
+)
+
+const otlpTracesPath = "/v1/traces"
+
+/*
+OTLPCollector an httptest.Server standing in for an OpenTelemetry collector, it receives OTLP/JSON trace exports.
+
+	collector := sysreporttest.NewOTLPCollector()
+	defer collector.Close()
+	transport := otlp.NewTransport(otlp.Config{Endpoint: collector.URL, HttpClient: collector.Client()})
+*/
+type OTLPCollector struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	requests   []otlp.ExportTraceServiceRequest
+	failures   int
+	failStatus int
+}
+
+// NewOTLPCollector starts a collector receiving spans on "/v1/traces"
+func NewOTLPCollector() *OTLPCollector {
+	c := &OTLPCollector{}
+	c.Server = httptest.NewServer(http.HandlerFunc(c.handle))
+	return c
+}
+
+// FailNext answers the next n exports with the status code, without storing them
+func (c *OTLPCollector) FailNext(n int, status int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures = n
+	c.failStatus = status
+}
+
+// Exports returns the received export requests, in the order they were received
+func (c *OTLPCollector) Exports() []otlp.ExportTraceServiceRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	requests := make([]otlp.ExportTraceServiceRequest, len(c.requests))
+	copy(requests, c.requests)
+	return requests
+}
+
+// Spans returns the received spans, in the order they were received
+func (c *OTLPCollector) Spans() []otlp.Span {
+	spans := []otlp.Span{}
+	for _, request := range c.Exports() {
+		for _, resourceSpans := range request.ResourceSpans {
+			for _, scopeSpans := range resourceSpans.ScopeSpans {
+				spans = append(spans, scopeSpans.Spans...)
+			}
+		}
+	}
+	return spans
+}
+
+// TraceSpans returns the received spans of the trace
+func (c *OTLPCollector) TraceSpans(traceID string) []otlp.Span {
+	spans := []otlp.Span{}
+	for _, span := range c.Spans() {
+		if span.TraceID == traceID {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+func (c *OTLPCollector) handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != otlpTracesPath {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if r.Header.Get("Content-Type") != "application/json" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	c.mu.Lock()
+	fail := c.failures > 0
+	failStatus := c.failStatus
+	if fail {
+		c.failures--
+	}
+	c.mu.Unlock()
+	if fail {
+		w.WriteHeader(failStatus)
+		return
+	}
+
+	request := otlp.ExportTraceServiceRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "failed to decode export request: %v", err)
+		return
+	}
+	c.mu.Lock()
+	c.requests = append(c.requests, request)
+	c.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte("{}"))
+}
@@ -0,0 +1,185 @@
+// fake event receiver for testing system reports end-to-end
+package sysreporttest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/armosec/logger-go/system-reports/datastructures"
+	"github.com/francoispqt/gojay"
+)
+
+const defaultEndpoint = "/k8s/sysreport"
+
+/*
+Server an httptest.Server mimicking the event receiver.
+
+It answers POST <endpoint> with a generated jobID for the first report of a job (a report without a jobID) and with "ok"
+afterwards, the same contract BaseReport.Send relies on. Faults can be scripted with FailNext, DropNext and SetLatency.
+
+	server := sysreporttest.NewServer()
+	defer server.Close()
+	reporter := datastructures.NewBaseReport("customer-guid", "my-component", server.URL, server.Client())
+*/
+type Server struct {
+	*httptest.Server
+	endpoint string
+
+	mu         sync.Mutex
+	reports    []*datastructures.BaseReport
+	requests   int
+	jobs       int
+	failures   int
+	failStatus int
+	drops      int
+	latency    time.Duration
+}
+
+// NewServer starts a server receiving reports on "/k8s/sysreport"
+func NewServer() *Server {
+	return NewServerWithEndpoint(defaultEndpoint)
+}
+
+// NewServerWithEndpoint starts a server receiving reports on the given endpoint path
+func NewServerWithEndpoint(endpoint string) *Server {
+	s := &Server{endpoint: endpoint}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// FailNext answers the next n reports with the status code, without storing them
+func (s *Server) FailNext(n int, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = n
+	s.failStatus = status
+}
+
+// DropNext closes the connection of the next n requests without answering
+func (s *Server) DropNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drops = n
+}
+
+// SetLatency delays every answer
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latency
+}
+
+// Requests returns the number of requests received, including the failed and dropped ones
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Reports returns the stored reports, in the order they were received. The jobID of the first report of a job is the one
+// the server assigned to it
+func (s *Server) Reports() []*datastructures.BaseReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reports := make([]*datastructures.BaseReport, len(s.reports))
+	copy(reports, s.reports)
+	return reports
+}
+
+// JobReports returns the stored reports of the job, in the order they were received
+func (s *Server) JobReports(jobID string) []*datastructures.BaseReport {
+	reports := []*datastructures.BaseReport{}
+	for _, report := range s.Reports() {
+		if report.JobID == jobID {
+			reports = append(reports, report)
+		}
+	}
+	return reports
+}
+
+// Reset forgets the stored reports and scripted faults
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reports = nil
+	s.requests = 0
+	s.failures = 0
+	s.drops = 0
+	s.latency = 0
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != s.endpoint {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests++
+	latency := s.latency
+	drop := s.drops > 0
+	if drop {
+		s.drops--
+	}
+	fail := !drop && s.failures > 0
+	failStatus := s.failStatus
+	if fail {
+		s.failures--
+	}
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(latency):
+		}
+	}
+	if drop {
+		dropConnection(w)
+		return
+	}
+	if fail {
+		w.WriteHeader(failStatus)
+		return
+	}
+
+	report := &datastructures.BaseReport{}
+	if err := gojay.NewDecoder(r.Body).DecodeObject(report); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "failed to decode report: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	body := "ok"
+	if report.JobID == "" {
+		s.jobs++
+		report.JobID = fmt.Sprintf("job-%d", s.jobs)
+		body = report.JobID
+	}
+	s.reports = append(s.reports, report)
+	s.mu.Unlock()
+
+	w.Write([]byte(body))
+}
+
+// dropConnection closes the connection without writing a response
+func dropConnection(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	conn.Close()
+}
@@ -0,0 +1,89 @@
+package sysreporttest
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/armosec/logger-go/system-reports/datastructures"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerAssignsJobIDs(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	reporter := datastructures.NewBaseReport("a-user-guid", "my-reporter", server.URL, server.Client())
+	reporter.SetRetryPolicy(datastructures.NoRetryPolicy{})
+	reporter.SetDetails("testing details")
+
+	errChan := make(chan error)
+	reporter.SendAsRoutine(true, errChan)
+	require.NoError(t, <-errChan)
+	reporter.SendAction("scanning", true, errChan)
+	require.NoError(t, <-errChan)
+	reporter.SendStatus(datastructures.JobSuccess, true, errChan)
+	require.NoError(t, <-errChan)
+
+	assert.Equal(t, "job-1", reporter.GetJobID())
+	reports := server.JobReports("job-1")
+	require.Len(t, reports, 3)
+	for i, report := range reports {
+		assert.Equal(t, fmt.Sprint(i+1), report.ActionID)
+		assert.Equal(t, "testing details", report.Details)
+	}
+	assert.Equal(t, "scanning", reports[1].ActionName)
+	assert.Equal(t, datastructures.JobSuccess, reports[2].Status)
+}
+
+func TestServerFaults(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	policy := &datastructures.ConstantRetryPolicy{MaxAttempts: 3, Delay: time.Millisecond}
+
+	t.Run("failures are retried", func(t *testing.T) {
+		server.Reset()
+		server.FailNext(2, http.StatusInternalServerError)
+		reporter := datastructures.NewBaseReport("a-user-guid", "my-reporter", server.URL, server.Client())
+		reporter.SetRetryPolicy(policy)
+		_, body, err := reporter.Send()
+		assert.NoError(t, err)
+		assert.Equal(t, reporter.GetJobID(), body)
+		assert.Equal(t, 3, server.Requests())
+		assert.Len(t, server.Reports(), 1)
+	})
+
+	t.Run("dropped connections are retried", func(t *testing.T) {
+		server.Reset()
+		server.DropNext(1)
+		reporter := datastructures.NewBaseReport("a-user-guid", "my-reporter", server.URL, server.Client())
+		reporter.SetRetryPolicy(policy)
+		_, _, err := reporter.Send()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, server.Requests())
+		assert.Len(t, server.Reports(), 1)
+	})
+
+	t.Run("permanent failures are not retried", func(t *testing.T) {
+		server.Reset()
+		server.FailNext(3, http.StatusUnauthorized)
+		reporter := datastructures.NewBaseReport("a-user-guid", "my-reporter", server.URL, server.Client())
+		reporter.SetRetryPolicy(policy)
+		_, _, err := reporter.Send()
+		assert.Error(t, err)
+		assert.Equal(t, 1, server.Requests())
+		assert.Empty(t, server.Reports())
+	})
+
+	t.Run("latency", func(t *testing.T) {
+		server.Reset()
+		server.SetLatency(50 * time.Millisecond)
+		reporter := datastructures.NewBaseReport("a-user-guid", "my-reporter", server.URL, server.Client())
+		start := time.Now()
+		_, _, err := reporter.Send()
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	})
+}
@@ -0,0 +1,101 @@
+package utilities
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSConfig the TLS configuration of the http client of the reporters
+type TLSConfig struct {
+	CertFile           string        // client certificate (PEM) for mTLS, optional
+	KeyFile            string        // key of the client certificate (PEM)
+	CAFile             string        // CA bundle (PEM) verifying the event receiver, default the system roots
+	ServerName         string        // server name to verify, default the host of the url
+	InsecureSkipVerify bool          // do not verify the event receiver certificate, for testing only
+	Timeout            time.Duration // request timeout, default none
+}
+
+/*
+NewTLSHttpClient returns an http client (an httputils.IHttpClient) for the reporters, with a client certificate for mTLS
+and/or a custom CA. The client certificate is loaded again when its files change, so rotated certificates are picked up
+
+	client, err := utilities.NewTLSHttpClient(utilities.TLSConfig{CertFile: "/certs/tls.crt", KeyFile: "/certs/tls.key", CAFile: "/certs/ca.crt"})
+	reporter := datastructures.NewBaseReport(customerGUID, "my-reporter", eventReceiverUrl, client)
+*/
+func NewTLSHttpClient(config TLSConfig) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify, // #nosec G402 explicitly requested
+	}
+	if config.CAFile != "" {
+		ca, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in CA file '%s'", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if config.CertFile != "" || config.KeyFile != "" {
+		if config.CertFile == "" || config.KeyFile == "" {
+			return nil, fmt.Errorf("both the client certificate and key files are required")
+		}
+		reloader := &certReloader{certFile: config.CertFile, keyFile: config.KeyFile}
+		if _, err := reloader.certificate(); err != nil {
+			return nil, err
+		}
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return reloader.certificate()
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport, Timeout: config.Timeout}, nil
+}
+
+// certReloader loads the client certificate again once its files changed
+type certReloader struct {
+	certFile, keyFile string
+
+	mu          sync.Mutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+func (r *certReloader) certificate() (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	certInfo, certErr := os.Stat(r.certFile)
+	keyInfo, keyErr := os.Stat(r.keyFile)
+	if certErr != nil || keyErr != nil {
+		if r.cert != nil {
+			return r.cert, nil
+		}
+		if certErr != nil {
+			return nil, fmt.Errorf("failed to read client certificate: %w", certErr)
+		}
+		return nil, fmt.Errorf("failed to read client key: %w", keyErr)
+	}
+	if r.cert != nil && certInfo.ModTime().Equal(r.certModTime) && keyInfo.ModTime().Equal(r.keyModTime) {
+		return r.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		// the files may be in the middle of a rotation, keep the previous certificate
+		if r.cert != nil {
+			return r.cert, nil
+		}
+		return nil, fmt.Errorf("failed to load client certificate: %w", err)
+	}
+	r.cert, r.certModTime, r.keyModTime = &cert, certInfo.ModTime(), keyInfo.ModTime()
+	return r.cert, nil
+}
@@ -8,21 +8,22 @@ import (
 	"github.com/armosec/armoapi-go/identifiers"
 	"github.com/armosec/logger-go/system-reports/datastructures"
 	"github.com/armosec/utils-go/httputils"
-	"github.com/golang/glog"
 )
 
 var (
 	EmptyString = []string{}
 )
 
-// TODO
-// takes annotation and return the jobID, annotationObject, err
+// GetJobIDByContext takes annotation and return the jobID, annotationObject of the context (see JobsAnnotations.ForContext), err
 func GetJobIDByContext(jobs []byte, context string) (string, datastructures.JobsAnnotations, error) {
 
 	var jobject datastructures.JobsAnnotations
-	err := json.Unmarshal(jobs, &jobject)
+	if err := json.Unmarshal(jobs, &jobject); err != nil {
+		return jobject.CurrJobID, jobject, err
+	}
+	jobject, _ = jobject.ForContext(context)
 
-	return jobject.CurrJobID, jobject, err
+	return jobject.CurrJobID, jobject, nil
 }
 
 func ProcessAnnotations(reporter datastructures.IReporter, jobAnnotations interface{}, hasAnnotations bool) error {
@@ -35,27 +36,55 @@ func ProcessAnnotations(reporter datastructures.IReporter, jobAnnotations interf
 	if err != nil {
 		return fmt.Errorf("unable to parse job annotations: %v", err)
 	}
+	applyJobsAnnotations(reporter, jobAnnotationsObj)
+	return nil
+}
 
+// ProcessObjectAnnotations sets the job of the reporter from the job of the context in the JobsAnnotationKey annotation
+// of a kubernetes object
+func ProcessObjectAnnotations(reporter datastructures.IReporter, annotations map[string]string, context string) error {
+	jobAnnotationsObj, found, err := datastructures.GetJobsAnnotations(annotations)
+	if !found {
+		return fmt.Errorf("missing job annotations")
+	}
+	if err != nil {
+		return fmt.Errorf("unable to parse job annotations: %w", err)
+	}
+	jobAnnotationsObj, _ = jobAnnotationsObj.ForContext(context)
+	applyJobsAnnotations(reporter, jobAnnotationsObj)
+	return nil
+}
+
+func applyJobsAnnotations(reporter datastructures.IReporter, jobAnnotationsObj datastructures.JobsAnnotations) {
 	if len(jobAnnotationsObj.CurrJobID) > 0 {
 		reporter.SetJobID(jobAnnotationsObj.CurrJobID)
 	}
 
 	reporter.SetParentAction(jobAnnotationsObj.ParentJobID)
+	if traceReporter, ok := reporter.(datastructures.ITraceReporter); ok {
+		if traceContext, ok := datastructures.ExtractTraceAnnotations(jobAnnotationsObj); ok {
+			traceReporter.SetTraceContext(traceContext)
+		}
+	}
+	if path, err := datastructures.ParseActionID(jobAnnotationsObj.LastActionID); err == nil && len(path) > 1 {
+		// a forked branch, eg. "3.1"
+		reporter.SetActionIDN(path[0])
+		reporter.SetActionID(jobAnnotationsObj.LastActionID)
+		return
+	}
 	reporter.SetActionID(jobAnnotationsObj.LastActionID)
 	actionID, _ := strconv.Atoi(reporter.GetActionID())
 	reporter.SetActionIDN(actionID)
-
-	return nil
 }
 
 // SendImmutableReport incase you want to send it all and just manage jobID, actionID yourself (no locking downtimes)
-func SendImmutableReport(target, reporter, actionID, action, status string, jobID *string, err error) {
+func SendImmutableReport(target, reporter, actionID, action string, status datastructures.StatusType, jobID *string, err error) {
 
 	lhs := datastructures.BaseReport{Reporter: reporter, ActionName: action, Target: target, JobID: *jobID, ActionID: actionID, Status: status}
 	lhs.ActionIDN, _ = strconv.Atoi(actionID)
 	if err != nil {
 		lhs.AddError(err.Error())
-		glog.Error(err.Error()) // TODO: remove log
+		lhs.Logger().Error("immutable report error", err, datastructures.F(datastructures.LogFieldReportID, lhs.GetReportID()), datastructures.F(datastructures.LogFieldJobID, lhs.JobID))
 	}
 	_, *jobID, _ = lhs.Send()
 